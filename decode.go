@@ -0,0 +1,172 @@
+package netpbm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Format identifie la famille d'une image netpbm.
+type Format int
+
+// Formats reconnus par Decode.
+const (
+	FormatPBM Format = iota + 1 // P1, P4
+	FormatPGM                   // P2, P5
+	FormatPPM                   // P3, P6
+	FormatPAM                   // P7
+	FormatPFM                   // PF, Pf
+)
+
+// String renvoie le nom usuel du format ("pbm", "pgm", ...).
+func (f Format) String() string {
+	switch f {
+	case FormatPBM:
+		return "pbm"
+	case FormatPGM:
+		return "pgm"
+	case FormatPPM:
+		return "ppm"
+	case FormatPAM:
+		return "pam"
+	case FormatPFM:
+		return "pfm"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// FormatOf renvoie le format correspondant à un numéro magique, ou 0 s'il est inconnu.
+func FormatOf(magicNumber string) Format {
+	switch magicNumber {
+	case "P1", "P4":
+		return FormatPBM
+	case "P2", "P5":
+		return FormatPGM
+	case "P3", "P6":
+		return FormatPPM
+	case "P7":
+		return FormatPAM
+	case "PF", "Pf":
+		return FormatPFM
+	}
+	return 0
+}
+
+// Image est l'interface commune à tous les types d'images du paquet (*PBM, *PGM, *PPM, *PAM, *PFM),
+// qui permet d'écrire une seule fois les filtres et outils génériques.
+//
+// Les échantillons sont accédés canal par canal : c va de 0 à Channels()-1 et les valeurs
+// vont de 0 (noir) à MaxValue(). Les coordonnées invalides sont ignorées par SetSample
+// et donnent 0 avec Sample.
+type Image interface {
+	// Size renvoie la largeur et la hauteur de l'image.
+	Size() (int, int)
+	// Channels renvoie le nombre d'échantillons par pixel.
+	Channels() int
+	// MaxValue renvoie la valeur maximale d'un échantillon.
+	MaxValue() uint
+	// Sample renvoie l'échantillon du canal c du pixel (x, y).
+	Sample(x, y, c int) uint16
+	// SetSample définit l'échantillon du canal c du pixel (x, y).
+	SetSample(x, y, c int, value uint16)
+	// Encode écrit l'image dans w dans son format d'origine.
+	Encode(w io.Writer) error
+	// Clone renvoie une copie indépendante de l'image.
+	Clone() Image
+}
+
+// Read lit une image netpbm de n'importe quel format depuis un fichier.
+// La lecture est stricte : voir ReadOptions et Strict.
+func Read(filename string) (Image, Format, error) {
+	img, format, _, err := ReadWithOptions(filename, ReadOptions{})
+	return img, format, err
+}
+
+// ReadWithOptions lit une image netpbm de n'importe quel format depuis un fichier selon opts et,
+// en mode Lenient, renvoie les anomalies corrigées.
+func ReadWithOptions(filename string, opts ReadOptions) (Image, Format, []*FormatError, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer file.Close()
+
+	return DecodeWithOptions(file, opts)
+}
+
+// Decode lit une image netpbm depuis r en détectant son format d'après le numéro magique.
+// Il renvoie l'image et son format ; la valeur concrète peut être obtenue par assertion de type.
+// La lecture est stricte et consomme r jusqu'au bout ; un Decoder lit les flux de plusieurs images.
+func Decode(r io.Reader) (Image, Format, error) {
+	img, format, _, err := DecodeWithOptions(r, ReadOptions{})
+	return img, format, err
+}
+
+// DecodeWithOptions lit une image netpbm de n'importe quel format depuis r selon opts et, en mode
+// Lenient, renvoie les anomalies corrigées. r est lu jusqu'au bout pour détecter les données en trop.
+func DecodeWithOptions(r io.Reader, opts ReadOptions) (Image, Format, []*FormatError, error) {
+	hr := newHeaderReaderWith(r, opts)
+
+	magicNumber, err := hr.magic("netpbm magic number", func(m string) bool { return FormatOf(m) != 0 })
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	img, format, err := decodeImageData(hr, magicNumber)
+	if err == nil {
+		err = hr.checkEnd()
+	}
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return img, format, hr.takeWarnings(), nil
+}
+
+// Probe lit uniquement l'en-tête d'une image P1 à P7 depuis r, sans lire ses données :
+// dimensions, profondeur, valeur maximale, numéro magique et commentaires.
+func Probe(r io.Reader) (Header, error) {
+	return ProbeWithOptions(r, ReadOptions{})
+}
+
+// ProbeWithOptions lit l'en-tête comme Probe, dans les limites de opts ; le mode, qui ne
+// concerne que les données, est sans effet.
+func ProbeWithOptions(r io.Reader, opts ReadOptions) (Header, error) {
+	return probe(newHeaderReaderWith(r, opts), "P1 to P7", func(m string) bool {
+		format := FormatOf(m)
+		return format != 0 && format != FormatPFM
+	})
+}
+
+// probe lit un en-tête complet dont le numéro magique doit satisfaire accept.
+func probe(hr *headerReader, expected string, accept func(string) bool) (Header, error) {
+	magicNumber, err := hr.magic(expected, accept)
+	if err != nil {
+		return Header{}, err
+	}
+	return hr.header(magicNumber)
+}
+
+// decodeImageData lit la suite d'une image de n'importe quel format dont le numéro magique a déjà été lu.
+func decodeImageData(hr *headerReader, magicNumber string) (Image, Format, error) {
+	format := FormatOf(magicNumber)
+	var img Image
+	var err error
+	switch format {
+	case FormatPBM:
+		img, err = decodePBM(hr, magicNumber)
+	case FormatPGM:
+		img, err = decodePGM(hr, magicNumber)
+	case FormatPPM:
+		img, err = decodePPM(hr, magicNumber)
+	case FormatPAM:
+		img, err = decodePAM(hr, magicNumber)
+	case FormatPFM:
+		img, err = decodePFM(hr, magicNumber)
+	default:
+		return nil, 0, hr.tokenError(ErrBadMagic, "magic number", "netpbm magic number", strconv.Quote(magicNumber))
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return img, format, nil
+}
@@ -0,0 +1,187 @@
+package netpbm
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Options règle l'écriture d'une image.Image par Encode.
+type Options struct {
+	// Format est le format cible (FormatPBM, FormatPGM ou FormatPPM) ;
+	// 0 le choisit d'après le modèle de couleur de l'image.
+	Format Format
+	// Plain demande la variante ASCII (P1, P2, P3) plutôt que la variante binaire (P4, P5, P6).
+	Plain bool
+	// Compact écrit les images PBM ASCII (P1) sans blanc entre les chiffres, comme pbmtext.
+	Compact bool
+}
+
+// magicFor renvoie le numéro magique du format, en variante ASCII ou binaire.
+func magicFor(format Format, plain bool) string {
+	n := int(format)
+	if !plain {
+		n += 3
+	}
+	return fmt.Sprintf("P%d", n)
+}
+
+// is16Bit indique si le modèle de couleur porte des échantillons sur 16 bits.
+func is16Bit(m color.Model) bool {
+	return m == color.Gray16Model || m == color.RGBA64Model || m == color.NRGBA64Model || m == color.Alpha16Model
+}
+
+// formatFor choisit le format netpbm le plus compact capable de représenter le modèle de couleur :
+// PBM pour une palette noir et blanc, PGM pour les niveaux de gris, PPM sinon.
+func formatFor(m color.Model) Format {
+	switch m {
+	case color.GrayModel, color.Gray16Model:
+		return FormatPGM
+	}
+	palette, ok := m.(color.Palette)
+	if !ok {
+		return FormatPPM
+	}
+	format := FormatPBM
+	for _, c := range palette {
+		g := color.Gray16Model.Convert(c).(color.Gray16)
+		r, gg, b, a := c.RGBA()
+		if a != 0xffff || r != gg || gg != b {
+			return FormatPPM
+		}
+		if g.Y != 0 && g.Y != 0xffff {
+			format = FormatPGM
+		}
+	}
+	return format
+}
+
+// PBMFromImage convertit une image.Image en PBM ; les pixels dont la luminance
+// est inférieure à la moitié de l'échelle deviennent noirs.
+func PBMFromImage(img image.Image) *PBM {
+	b := img.Bounds()
+	pbm := NewPBM(b.Dx(), b.Dy())
+	for y := 0; y < pbm.Height; y++ {
+		row := pbm.Row(y)
+		for x := range row {
+			g := color.Gray16Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray16)
+			row[x] = g.Y < 0x8000
+		}
+	}
+	return pbm
+}
+
+// PGMFromImage convertit une image.Image en PGM, sur 16 bits si le modèle de couleur l'est.
+func PGMFromImage(img image.Image) *PGM {
+	b := img.Bounds()
+	pgm := NewPGM(b.Dx(), b.Dy(), 255)
+	if is16Bit(img.ColorModel()) {
+		pgm.Max = 65535
+	}
+	for y := 0; y < pgm.Height; y++ {
+		row := pgm.Row(y)
+		for x := range row {
+			g := color.Gray16Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray16)
+			row[x] = scaleSample(g.Y, 0xffff, pgm.Max)
+		}
+	}
+	return pgm
+}
+
+// PPMFromImage convertit une image.Image en PPM, sur 16 bits si le modèle de couleur l'est.
+// Les pixels transparents sont composés sur un fond noir.
+func PPMFromImage(img image.Image) *PPM {
+	b := img.Bounds()
+	ppm := NewPPM(b.Dx(), b.Dy(), 255)
+	if is16Bit(img.ColorModel()) {
+		ppm.Max = 65535
+	}
+	for y := 0; y < ppm.Height; y++ {
+		row := ppm.Row(y)
+		for x := range row {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			row[x] = Pixel{
+				R: scaleSample(uint16(r), 0xffff, ppm.Max),
+				G: scaleSample(uint16(g), 0xffff, ppm.Max),
+				B: scaleSample(uint16(bl), 0xffff, ppm.Max),
+			}
+		}
+	}
+	return ppm
+}
+
+// FromImage convertit une image.Image vers le format demandé ; un format nul
+// est choisi d'après le modèle de couleur de l'image.
+func FromImage(img image.Image, format Format) (Image, error) {
+	if format == 0 {
+		format = formatFor(img.ColorModel())
+	}
+	switch format {
+	case FormatPBM:
+		return PBMFromImage(img), nil
+	case FormatPGM:
+		return PGMFromImage(img), nil
+	case FormatPPM:
+		return PPMFromImage(img), nil
+	}
+	return nil, fmt.Errorf("cannot convert image.Image to %v", format)
+}
+
+// Encode écrit n'importe quelle image.Image dans w au format netpbm décrit par opts (qui peut être nil).
+// Une image *PBM, *PGM ou *PPM déjà au bon format est écrite sans conversion.
+func Encode(w io.Writer, img image.Image, opts *Options) error {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Format == 0 {
+		o.Format = formatFor(img.ColorModel())
+	}
+	magicNumber := magicFor(o.Format, o.Plain)
+
+	// les copies superficielles permettent de changer le numéro magique sans modifier img
+	switch m := img.(type) {
+	case *PBM:
+		if o.Format == FormatPBM {
+			c := *m
+			c.MagicNumber = magicNumber
+			c.Compact = c.Compact || o.Compact
+			return c.Encode(w)
+		}
+	case *PackedPBM:
+		if o.Format == FormatPBM {
+			c := *m
+			c.MagicNumber = magicNumber
+			c.Compact = c.Compact || o.Compact
+			return c.Encode(w)
+		}
+	case *PGM:
+		if o.Format == FormatPGM {
+			c := *m
+			c.MagicNumber = magicNumber
+			return c.Encode(w)
+		}
+	case *PPM:
+		if o.Format == FormatPPM {
+			c := *m
+			c.MagicNumber = magicNumber
+			return c.Encode(w)
+		}
+	}
+
+	converted, err := FromImage(img, o.Format)
+	if err != nil {
+		return err
+	}
+	switch c := converted.(type) {
+	case *PBM:
+		c.MagicNumber = magicNumber
+		c.Compact = o.Compact
+	case *PGM:
+		c.MagicNumber = magicNumber
+	case *PPM:
+		c.MagicNumber = magicNumber
+	}
+	return converted.Encode(w)
+}
@@ -0,0 +1,70 @@
+package netpbm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Erreurs sentinelles renvoyées, enveloppées dans un *FormatError, par tous les lecteurs du paquet.
+// On les reconnaît avec errors.Is.
+var (
+	// ErrBadMagic signale un numéro magique inconnu ou d'un autre format que celui attendu.
+	ErrBadMagic = errors.New("netpbm: bad magic number")
+	// ErrBadHeader signale un champ d'en-tête invalide : dimensions, valeur maximale, mot-clé PAM, échelle PFM...
+	ErrBadHeader = errors.New("netpbm: bad header")
+	// ErrBadSample signale un échantillon ASCII invalide dans les données.
+	ErrBadSample = errors.New("netpbm: bad sample")
+	// ErrLimit signale un fichier qui dépasse les limites de ressources du décodeur (voir Limits).
+	ErrLimit = errors.New("netpbm: limit exceeded")
+	// ErrTruncated signale un fichier qui se termine avant la fin de l'en-tête ou des données.
+	ErrTruncated = errors.New("netpbm: truncated data")
+	// ErrTrailingData signale des données, autres que des blancs et des commentaires, après la fin de l'image.
+	ErrTrailingData = errors.New("netpbm: trailing data")
+)
+
+// FormatError décrit précisément une erreur de décodage : où elle s'est produite,
+// sur quel champ, et ce qui était attendu à la place de ce qui a été lu.
+type FormatError struct {
+	// Offset est la position, en octets depuis le début du flux, du jeton ou de la donnée fautive.
+	Offset int64
+	// Line est le numéro de ligne (à partir de 1) correspondant à Offset ; il n'a de sens que dans
+	// l'en-tête et les données ASCII.
+	Line int
+	// Field nomme le champ fautif : "magic number", "width", "max value", "sample", ...
+	Field string
+	// Expected et Got décrivent la valeur attendue et la valeur lue ; ils peuvent être vides.
+	Expected, Got string
+	// Err est l'erreur sentinelle (ErrBadMagic, ErrBadHeader, ErrBadSample, ErrLimit, ErrTruncated,
+	// ErrTrailingData) ou l'erreur d'entrée-sortie sous-jacente.
+	Err error
+}
+
+// Error renvoie un message de la forme
+// `netpbm: bad header: width: expected non-negative integer, got "abc" (line 2, offset 3)`.
+func (e *FormatError) Error() string {
+	var sb strings.Builder
+	if e.Err != nil {
+		sb.WriteString(e.Err.Error())
+	} else {
+		sb.WriteString("netpbm: format error")
+	}
+	if e.Field != "" {
+		sb.WriteString(": " + e.Field)
+	}
+	switch {
+	case e.Expected != "" && e.Got != "":
+		fmt.Fprintf(&sb, ": expected %s, got %s", e.Expected, e.Got)
+	case e.Expected != "":
+		fmt.Fprintf(&sb, ": expected %s", e.Expected)
+	case e.Got != "":
+		fmt.Fprintf(&sb, ": got %s", e.Got)
+	}
+	fmt.Fprintf(&sb, " (line %d, offset %d)", e.Line, e.Offset)
+	return sb.String()
+}
+
+// Unwrap renvoie Err, pour errors.Is et errors.As.
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
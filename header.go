@@ -0,0 +1,312 @@
+package netpbm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Header décrit l'en-tête d'une image netpbm, sans ses données.
+type Header struct {
+	MagicNumber   string
+	Format        Format
+	Width, Height int
+	// Depth est le nombre d'échantillons par pixel : 1 pour PBM et PGM, 3 pour PPM.
+	Depth int
+	// Max est la valeur maximale d'un échantillon, toujours 1 pour PBM.
+	Max uint
+	// TupleType est le type de tuple d'une image PAM.
+	TupleType string
+	// Comments contient les commentaires de l'en-tête, dans l'ordre, sans le '#' ni les blancs qui les entourent.
+	Comments []string
+}
+
+// headerReader découpe l'en-tête d'une image netpbm en jetons, comme le prévoit la norme :
+// les jetons sont séparés par des blancs quelconques et un commentaire, introduit par '#',
+// peut apparaître n'importe où dans l'en-tête et s'étend jusqu'à la fin de la ligne.
+// Le blanc qui suit le dernier jeton est consommé, si bien que les données binaires
+// commencent exactement à la position courante du lecteur.
+//
+// Toutes les lectures, y compris celles des données, passent par le headerReader afin qu'il
+// connaisse la position courante dans le flux et puisse la rapporter dans un *FormatError.
+type headerReader struct {
+	r     *bufio.Reader
+	delim byte // blanc qui a terminé le dernier jeton
+
+	offset, line           int64 // position courante : octets consommés et numéro de ligne
+	tokenOffset, tokenLine int64 // position du début du dernier jeton
+
+	limits       Limits
+	mode         Mode
+	truncated    bool           // données tronquées tolérées en mode Lenient : les échantillons suivants valent 0
+	comments     []string       // commentaires de l'en-tête en cours de lecture
+	commentBytes int            // longueur totale de comments
+	headerDone   bool           // l'en-tête a été lu : les commentaires ne sont plus conservés
+	warnings     []*FormatError // anomalies corrigées en mode Lenient depuis le dernier appel à takeWarnings
+}
+
+// newHeaderReader crée un headerReader lisant depuis r avec les limites DefaultLimits.
+func newHeaderReader(r io.Reader) *headerReader {
+	return &headerReader{r: newReader(r), line: 1, limits: DefaultLimits}
+}
+
+// isSpace indique si c est un blanc au sens de la norme netpbm.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+// readByte lit un octet en tenant la position à jour.
+func (h *headerReader) readByte() (byte, error) {
+	c, err := h.r.ReadByte()
+	if err == nil {
+		h.offset++
+		if c == '\n' {
+			h.line++
+		}
+	}
+	return c, err
+}
+
+// unreadByte remet dans le lecteur le dernier octet lu, qui n'est jamais un saut de ligne.
+func (h *headerReader) unreadByte() {
+	h.r.UnreadByte()
+	h.offset--
+}
+
+// readLine lit la suite de la ligne courante, saut de ligne compris. Elle échoue avec ErrLimit
+// si la ligne, sans son saut de ligne, dépasse max octets (0 : pas de limite) ; field nomme la
+// ligne dans ce cas.
+func (h *headerReader) readLine(field string, max int) (string, error) {
+	var line []byte
+	for {
+		chunk, err := h.r.ReadSlice('\n')
+		h.offset += int64(len(chunk))
+		line = append(line, chunk...)
+		if n := len(bytes.TrimSuffix(line, []byte{'\n'})); max > 0 && n > max {
+			return "", h.readError(ErrLimit, field, fmt.Sprintf("at most %d bytes", max), strconv.Itoa(n))
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == nil {
+			h.line++
+		}
+		return string(line), err
+	}
+}
+
+// readFull lit exactement len(buf) octets de données binaires.
+func (h *headerReader) readFull(buf []byte) error {
+	n, err := io.ReadFull(h.r, buf)
+	h.offset += int64(n)
+	return err
+}
+
+// tokenError renvoie un *FormatError situé au début du dernier jeton lu.
+func (h *headerReader) tokenError(err error, field, expected, got string) *FormatError {
+	return &FormatError{Offset: h.tokenOffset, Line: int(h.tokenLine), Field: field, Expected: expected, Got: got, Err: err}
+}
+
+// readError renvoie un *FormatError situé à la position courante pour une erreur de lecture :
+// une fin de fichier prématurée devient ErrTruncated. Un *FormatError est renvoyé tel quel.
+func (h *headerReader) readError(err error, field, expected, got string) *FormatError {
+	if fe, ok := err.(*FormatError); ok {
+		return fe
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = ErrTruncated
+	}
+	return &FormatError{Offset: h.offset, Line: int(h.line), Field: field, Expected: expected, Got: got, Err: err}
+}
+
+// readComment consomme la fin d'un commentaire, jusqu'au saut de ligne inclus. Tant que l'en-tête
+// n'a pas été entièrement lu, le commentaire est conservé dans les limites MaxComments et
+// MaxCommentBytes ; ensuite, il est simplement ignoré.
+func (h *headerReader) readComment() error {
+	line, err := h.readLine("comment", h.limits.MaxCommentLength)
+	if (err != nil && err != io.EOF) || h.headerDone {
+		return err
+	}
+	if max := h.limits.MaxComments; max > 0 && len(h.comments) >= max {
+		return h.readError(ErrLimit, "comments", fmt.Sprintf("at most %d comments", max), strconv.Itoa(len(h.comments)+1))
+	}
+	comment := strings.TrimSpace(line)
+	h.commentBytes += len(comment)
+	if max := h.limits.MaxCommentBytes; max > 0 && h.commentBytes > max {
+		return h.readError(ErrLimit, "comments", fmt.Sprintf("at most %d bytes of comments", max), strconv.Itoa(h.commentBytes))
+	}
+	h.comments = append(h.comments, comment)
+	return err
+}
+
+// commentSanitizer remplace les sauts de ligne d'un commentaire, qui le feraient déborder dans l'en-tête.
+var commentSanitizer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// writeComments écrit chaque commentaire sur sa propre ligne "# texte", après remplacement
+// de ses sauts de ligne par des espaces.
+func writeComments(w io.Writer, comments []string) error {
+	for _, comment := range comments {
+		if _, err := fmt.Fprintf(w, "# %s\n", commentSanitizer.Replace(comment)); err != nil {
+			return fmt.Errorf("failed to write comments: %v", err)
+		}
+	}
+	return nil
+}
+
+// takeComments marque la fin de l'en-tête et renvoie ses commentaires : les commentaires
+// suivants, dans les données ou après l'image, ne sont plus conservés.
+func (h *headerReader) takeComments() []string {
+	comments := h.comments
+	h.comments, h.commentBytes, h.headerDone = nil, 0, true
+	return comments
+}
+
+// token renvoie le prochain jeton de l'en-tête en ignorant blancs et commentaires.
+// Le blanc qui termine le jeton est consommé ; si le jeton est immédiatement suivi d'un commentaire,
+// celui-ci est consommé avec son saut de ligne, qui sert alors de blanc délimiteur : dans
+// "P5 1 1 255#c\n", les données binaires commencent juste après le saut de ligne.
+func (h *headerReader) token() (string, error) {
+	// ignore les blancs et les commentaires
+	for {
+		c, err := h.readByte()
+		if err != nil {
+			return "", err
+		}
+		if c == '#' {
+			if err := h.readComment(); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if !isSpace(c) {
+			h.unreadByte()
+			break
+		}
+	}
+
+	var sb strings.Builder
+	h.delim = 0
+	h.tokenOffset, h.tokenLine = h.offset, h.line
+	for {
+		c, err := h.readByte()
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if c == '#' {
+			// le saut de ligne qui termine le commentaire tient lieu de blanc délimiteur
+			if err := h.readComment(); err != nil && err != io.EOF {
+				return "", err
+			}
+			h.delim = '\n'
+			return sb.String(), nil
+		}
+		if isSpace(c) {
+			h.delim = c
+			return sb.String(), nil
+		}
+		if sb.Len() == maxTokenLength {
+			return "", h.tokenError(ErrBadHeader, "token", fmt.Sprintf("at most %d bytes", maxTokenLength), strconv.Quote(sb.String()[:16])+"...")
+		}
+		sb.WriteByte(c)
+	}
+}
+
+// magic lit le numéro magique ; accept indique si le numéro convient et expected le décrit.
+func (h *headerReader) magic(expected string, accept func(string) bool) (string, error) {
+	magicNumber, err := h.token()
+	if err != nil {
+		return "", h.readError(err, "magic number", expected, "")
+	}
+	if !accept(magicNumber) {
+		return "", h.tokenError(ErrBadMagic, "magic number", expected, strconv.Quote(magicNumber))
+	}
+	return magicNumber, nil
+}
+
+// int lit un entier positif ou nul ; field nomme le champ dans les messages d'erreur.
+func (h *headerReader) int(field string) (int, error) {
+	tok, err := h.token()
+	if err == nil && tok == "" {
+		err = io.EOF
+	}
+	if err != nil {
+		return 0, h.readError(err, field, "non-negative integer", "")
+	}
+	value, err := strconv.Atoi(tok)
+	if err != nil || value < 0 {
+		return 0, h.tokenError(ErrBadHeader, field, "non-negative integer", strconv.Quote(tok))
+	}
+	return value, nil
+}
+
+// dimensions lit la largeur puis la hauteur de l'image.
+func (h *headerReader) dimensions() (int, int, error) {
+	width, err := h.int("width")
+	if err != nil {
+		return 0, 0, err
+	}
+	height, err := h.int("height")
+	if err != nil {
+		return 0, 0, err
+	}
+	return width, height, nil
+}
+
+// maxValue lit la valeur maximale des échantillons et vérifie qu'elle est comprise entre 1 et 65535.
+func (h *headerReader) maxValue() (uint, error) {
+	value, err := h.int("max value")
+	if err != nil {
+		return 0, err
+	}
+	if err := h.checkMaxValue("max value", value); err != nil {
+		return 0, err
+	}
+	return uint(value), nil
+}
+
+// restOfLine renvoie le reste de la ligne courante, sans les blancs qui l'entourent.
+func (h *headerReader) restOfLine() (string, error) {
+	if h.delim == '\n' {
+		return "", nil
+	}
+	line, err := h.readLine("header line", maxTokenLength)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// header lit la suite de l'en-tête d'une image P1 à P7 dont le numéro magique a déjà été lu.
+func (h *headerReader) header(magicNumber string) (Header, error) {
+	header := Header{MagicNumber: magicNumber, Format: FormatOf(magicNumber), Depth: 1, Max: 1}
+	switch header.Format {
+	case FormatPAM:
+		return readPAMHeader(h, magicNumber)
+	case FormatPBM, FormatPGM, FormatPPM:
+	default:
+		return Header{}, h.tokenError(ErrBadMagic, "magic number", "P1 to P7", strconv.Quote(magicNumber))
+	}
+
+	var err error
+	header.Width, header.Height, err = h.dimensions()
+	if err != nil {
+		return Header{}, err
+	}
+	if header.Format != FormatPBM {
+		header.Max, err = h.maxValue()
+		if err != nil {
+			return Header{}, err
+		}
+	}
+	if header.Format == FormatPPM {
+		header.Depth = 3
+	}
+	header.Comments = h.takeComments()
+	return header, nil
+}
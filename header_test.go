@@ -0,0 +1,38 @@
+package netpbm
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCommentAfterLastToken vérifie qu'un commentaire collé au dernier jeton de l'en-tête est
+// consommé avec son saut de ligne, les données binaires commençant juste après.
+func TestCommentAfterLastToken(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []uint16
+	}{
+		{"P4 1 1#c\n\x80", []uint16{0}},
+		{"P5 1 1 255#c\n\x05", []uint16{5}},
+		{"P6 1 1 255#c\n\x01\x02\x03", []uint16{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		img, _, err := Decode(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("%q: Decode: %v", tt.input, err)
+		}
+		for c, want := range tt.want {
+			if got := img.Sample(0, 0, c); got != want {
+				t.Fatalf("%q: sample %d = %d, want %d", tt.input, c, got, want)
+			}
+		}
+	}
+
+	pgm, err := DecodePGM(strings.NewReader("P5 1 1 255#c\n\x05"))
+	if err != nil {
+		t.Fatalf("DecodePGM: %v", err)
+	}
+	if len(pgm.Comments) != 1 || pgm.Comments[0] != "c" {
+		t.Fatalf("Comments = %q, want [\"c\"]", pgm.Comments)
+	}
+}
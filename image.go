@@ -0,0 +1,151 @@
+package netpbm
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// pbmPalette est le modèle de couleur des images PBM : blanc (0) et noir (1).
+var pbmPalette = color.Palette{color.White, color.Black}
+
+func init() {
+	image.RegisterFormat("pbm", "P1", decodeImage, DecodeConfig)
+	image.RegisterFormat("pbm", "P4", decodeImage, DecodeConfig)
+	image.RegisterFormat("pgm", "P2", decodeImage, DecodeConfig)
+	image.RegisterFormat("pgm", "P5", decodeImage, DecodeConfig)
+	image.RegisterFormat("ppm", "P3", decodeImage, DecodeConfig)
+	image.RegisterFormat("ppm", "P6", decodeImage, DecodeConfig)
+}
+
+// decodeImage est la fonction de décodage enregistrée auprès du paquet image.
+func decodeImage(r io.Reader) (image.Image, error) {
+	img, _, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := img.(image.Image); ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("netpbm image does not implement image.Image")
+}
+
+// DecodeConfig lit uniquement l'en-tête d'une image P1 à P6 et renvoie ses dimensions et son
+// modèle de couleur. Elle est enregistrée auprès de image.DecodeConfig ; Probe donne en plus
+// la valeur maximale, le numéro magique et les commentaires.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	return DecodeConfigWithOptions(r, ReadOptions{})
+}
+
+// DecodeConfigWithOptions lit l'en-tête comme DecodeConfig, dans les limites de opts ; le mode,
+// qui ne concerne que les données, est sans effet.
+func DecodeConfigWithOptions(r io.Reader, opts ReadOptions) (image.Config, error) {
+	header, err := probe(newHeaderReaderWith(r, opts), "P1 to P6", func(m string) bool {
+		format := FormatOf(m)
+		return format == FormatPBM || format == FormatPGM || format == FormatPPM
+	})
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: colorModel(header.Format, header.Max), Width: header.Width, Height: header.Height}, nil
+}
+
+// colorModel renvoie le modèle de couleur Go adapté au format et à la valeur maximale.
+func colorModel(format Format, max uint) color.Model {
+	switch {
+	case format == FormatPBM:
+		return pbmPalette
+	case format == FormatPGM && max > 255:
+		return color.Gray16Model
+	case format == FormatPGM:
+		return color.GrayModel
+	case max > 255:
+		return color.RGBA64Model
+	}
+	return color.RGBAModel
+}
+
+// ColorModel renvoie le modèle de couleur de l'image (image.Image).
+func (pbm *PBM) ColorModel() color.Model {
+	return pbmPalette
+}
+
+// Bounds renvoie le rectangle occupé par l'image (image.Image).
+func (pbm *PBM) Bounds() image.Rectangle {
+	return image.Rect(0, 0, pbm.Width, pbm.Height)
+}
+
+// At renvoie la couleur du pixel (x, y) : noir ou blanc (image.Image).
+func (pbm *PBM) At(x, y int) color.Color {
+	if pbm.BitAt(x, y) {
+		return color.Black
+	}
+	return color.White
+}
+
+// ColorModel renvoie le modèle de couleur de l'image (image.Image).
+func (p *PackedPBM) ColorModel() color.Model {
+	return pbmPalette
+}
+
+// Bounds renvoie le rectangle occupé par l'image (image.Image).
+func (p *PackedPBM) Bounds() image.Rectangle {
+	return image.Rect(0, 0, p.Width, p.Height)
+}
+
+// At renvoie la couleur du pixel (x, y) : noir ou blanc (image.Image).
+func (p *PackedPBM) At(x, y int) color.Color {
+	if p.BitAt(x, y) {
+		return color.Black
+	}
+	return color.White
+}
+
+// ColorModel renvoie le modèle de couleur de l'image (image.Image).
+func (pgm *PGM) ColorModel() color.Model {
+	return colorModel(FormatPGM, pgm.Max)
+}
+
+// Bounds renvoie le rectangle occupé par l'image (image.Image).
+func (pgm *PGM) Bounds() image.Rectangle {
+	return image.Rect(0, 0, pgm.Width, pgm.Height)
+}
+
+// At renvoie la couleur du pixel (x, y), remise à l'échelle 8 ou 16 bits (image.Image).
+func (pgm *PGM) At(x, y int) color.Color {
+	v := pgm.GrayAt(x, y)
+	if pgm.Max > 255 {
+		return color.Gray16{Y: scaleSample(v, pgm.Max, 0xffff)}
+	}
+	return color.Gray{Y: uint8(scaleSample(v, pgm.Max, 0xff))}
+}
+
+// ColorModel renvoie le modèle de couleur de l'image (image.Image).
+func (ppm *PPM) ColorModel() color.Model {
+	return colorModel(FormatPPM, ppm.Max)
+}
+
+// Bounds renvoie le rectangle occupé par l'image (image.Image).
+func (ppm *PPM) Bounds() image.Rectangle {
+	return image.Rect(0, 0, ppm.Width, ppm.Height)
+}
+
+// At renvoie la couleur opaque du pixel (x, y), remise à l'échelle 8 ou 16 bits (image.Image).
+func (ppm *PPM) At(x, y int) color.Color {
+	p := ppm.PixelAt(x, y)
+	if ppm.Max > 255 {
+		return color.RGBA64{
+			R: scaleSample(p.R, ppm.Max, 0xffff),
+			G: scaleSample(p.G, ppm.Max, 0xffff),
+			B: scaleSample(p.B, ppm.Max, 0xffff),
+			A: 0xffff,
+		}
+	}
+	return color.RGBA{
+		R: uint8(scaleSample(p.R, ppm.Max, 0xff)),
+		G: uint8(scaleSample(p.G, ppm.Max, 0xff)),
+		B: uint8(scaleSample(p.B, ppm.Max, 0xff)),
+		A: 0xff,
+	}
+}
@@ -0,0 +1,95 @@
+package netpbm
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Limits borne les ressources qu'un fichier peut exiger du décodeur, afin qu'un en-tête hostile
+// (par exemple 2000000000x2000000000) soit rejeté avant toute allocation. Un champ nul désactive
+// la limite correspondante.
+type Limits struct {
+	// MaxPixels borne Width*Height.
+	MaxPixels int64
+	// MaxBytes borne la taille des données de l'image, comptée comme dans un fichier binaire
+	// (P4 à P7, PF) même lorsque le fichier est au format ASCII.
+	MaxBytes int64
+	// MaxValue borne la valeur maximale des échantillons (maxval).
+	MaxValue uint
+	// MaxCommentLength borne la longueur d'un commentaire de l'en-tête, en octets.
+	MaxCommentLength int
+	// MaxComments borne le nombre de commentaires de l'en-tête conservés dans Comments.
+	MaxComments int
+	// MaxCommentBytes borne la longueur totale, en octets, des commentaires conservés.
+	MaxCommentBytes int
+}
+
+// DefaultLimits sont les limites appliquées par tous les lecteurs du paquet, sauf indication
+// contraire (voir ReadOptions.Limits et Decoder.Limits). Elles acceptent des images de plus de 250 mégapixels.
+var DefaultLimits = Limits{
+	MaxPixels:        1 << 28,
+	MaxBytes:         1 << 31,
+	MaxValue:         MaxValue16,
+	MaxCommentLength: 1 << 16,
+	MaxComments:      1 << 12,
+	MaxCommentBytes:  1 << 20,
+}
+
+// maxTokenLength est la longueur maximale d'un jeton ou d'une ligne de l'en-tête autre qu'un commentaire.
+const maxTokenLength = 1 << 12
+
+// checkMaxValue vérifie qu'une valeur maximale lue dans le champ field est valide et respecte les limites.
+func (h *headerReader) checkMaxValue(field string, value int) error {
+	if value < 1 || value > MaxValue16 {
+		return h.tokenError(ErrBadHeader, field, "1 to 65535", strconv.Itoa(value))
+	}
+	if max := h.limits.MaxValue; max > 0 && uint(value) > max {
+		return h.tokenError(ErrLimit, field, fmt.Sprintf("at most %d", max), strconv.Itoa(value))
+	}
+	return nil
+}
+
+// mulSize renvoie a*b pour a et b positifs ou nuls, ou false si le produit ne tient pas dans un int.
+func mulSize(a, b int64) (int64, bool) {
+	if a != 0 && b > math.MaxInt/a {
+		return 0, false
+	}
+	return a * b, true
+}
+
+// checkSize vérifie, avant d'allouer l'image, que ses dimensions respectent les limites. L'image
+// compte width x height pixels de depth échantillons de bitsPerSample bits chacun (1 pour PBM,
+// 8 ou 16 pour PGM, PPM et PAM, 32 pour PFM). Une image dont la taille, en échantillons ou en
+// octets, ne tient pas dans un int est rejetée avec ErrLimit quelles que soient les limites.
+func (h *headerReader) checkSize(width, height, depth, bitsPerSample int) error {
+	if width == 0 || height == 0 {
+		return nil
+	}
+	size := fmt.Sprintf("%dx%d", width, height)
+	w, hh := int64(width), int64(height)
+
+	rowSamples, ok := mulSize(w, int64(depth))
+	var rowBits, dataBytes int64
+	if ok {
+		// les échantillons occupent au plus 4 octets en mémoire (float32 pour PFM)
+		_, ok = mulSize(rowSamples, 4*hh)
+	}
+	if ok {
+		rowBits, ok = mulSize(rowSamples, int64(bitsPerSample))
+	}
+	if ok {
+		dataBytes, ok = mulSize((rowBits+7)/8, hh)
+	}
+	if !ok {
+		return h.tokenError(ErrLimit, "size", "image that fits in memory", fmt.Sprintf("%dx%dx%d", width, height, depth))
+	}
+
+	if max := h.limits.MaxPixels; max > 0 && hh > max/w {
+		return h.tokenError(ErrLimit, "size", fmt.Sprintf("at most %d pixels", max), size)
+	}
+	if max := h.limits.MaxBytes; max > 0 && dataBytes > max {
+		return h.tokenError(ErrLimit, "size", fmt.Sprintf("at most %d bytes of pixel data", max), size)
+	}
+	return nil
+}
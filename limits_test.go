@@ -0,0 +1,126 @@
+package netpbm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestSizeOverflow vérifie que les en-têtes dont la taille déborde d'un int sont rejetés
+// avec ErrLimit avant toute allocation, même lorsque les limites sont désactivées.
+func TestSizeOverflow(t *testing.T) {
+	const pam = "P7\nWIDTH 3\nHEIGHT 1\nDEPTH 6148914691236517206\nMAXVAL 255\nENDHDR\n\x00\x00"
+	const ppm = "P6 3074457345618258603 1 65535\n\x00\x00"
+
+	if _, err := DecodePAM(strings.NewReader(pam)); !errors.Is(err, ErrLimit) {
+		t.Fatalf("DecodePAM: got %v, want ErrLimit", err)
+	}
+	if _, _, err := Decode(strings.NewReader(pam)); !errors.Is(err, ErrLimit) {
+		t.Fatalf("Decode: got %v, want ErrLimit", err)
+	}
+
+	for _, limits := range []Limits{{MaxBytes: 1 << 31}, {}} {
+		limits := limits
+		if _, _, err := DecodePPMWithOptions(strings.NewReader(ppm), ReadOptions{Limits: &limits}); !errors.Is(err, ErrLimit) {
+			t.Fatalf("DecodePPMWithOptions(%+v): got %v, want ErrLimit", limits, err)
+		}
+		d := NewDecoder(strings.NewReader(pam))
+		d.Limits = &limits
+		if _, _, err := d.Decode(); !errors.Is(err, ErrLimit) {
+			t.Fatalf("Decoder(%+v): got %v, want ErrLimit", limits, err)
+		}
+	}
+}
+
+// TestCommentLimits vérifie que le nombre et la taille totale des commentaires conservés sont
+// bornés, et que les commentaires qui suivent l'en-tête ne sont pas conservés.
+func TestCommentLimits(t *testing.T) {
+	many := "P2\n" + strings.Repeat("#\n", DefaultLimits.MaxComments+1) + "1 1 255 0"
+	if _, err := Probe(strings.NewReader(many)); !errors.Is(err, ErrLimit) {
+		t.Fatalf("%d comments: got %v, want ErrLimit", DefaultLimits.MaxComments+1, err)
+	}
+	long := "P2\n" + strings.Repeat("#"+strings.Repeat("a", 1<<15)+"\n", 40) + "1 1 255 0"
+	if _, err := Probe(strings.NewReader(long)); !errors.Is(err, ErrLimit) {
+		t.Fatalf("%d bytes of comments: got %v, want ErrLimit", 40<<15, err)
+	}
+
+	pgm, err := DecodePGM(strings.NewReader("P2\n# header\n2 1 255\n# data\n1 2\n# end\n"))
+	if err != nil {
+		t.Fatalf("DecodePGM: %v", err)
+	}
+	if len(pgm.Comments) != 1 || pgm.Comments[0] != "header" {
+		t.Fatalf("Comments = %q, want [\"header\"]", pgm.Comments)
+	}
+}
+
+// TestPerCallLimits vérifie que chaque point d'entrée applique les limites passées dans
+// ReadOptions, et DefaultLimits lorsqu'elles sont nil : les décodeurs bornent la taille de
+// l'image, Probe et DecodeConfig la longueur des commentaires de l'en-tête.
+func TestPerCallLimits(t *testing.T) {
+	ppm, pam := NewPPM(4, 4, 255), NewPAM(4, 4, 1, 255, "GRAYSCALE")
+	ppm.Comments, pam.Comments = []string{"a comment"}, []string{"a comment"}
+	images := map[string]Image{
+		"ppm": ppm,
+		"pam": pam,
+		"pfm": NewPFM(4, 4, "Pf"),
+		"pbm": NewPackedPBM(4, 4),
+	}
+	encoded := map[string]string{}
+	for name, img := range images {
+		var buf bytes.Buffer
+		if err := img.Encode(&buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		encoded[name] = buf.String()
+	}
+
+	decoders := []struct {
+		name, image string
+		decode      func(r io.Reader, opts ReadOptions) error
+	}{
+		{"DecodeWithOptions", "ppm", func(r io.Reader, opts ReadOptions) error {
+			_, _, _, err := DecodeWithOptions(r, opts)
+			return err
+		}},
+		{"DecodePAMWithOptions", "pam", func(r io.Reader, opts ReadOptions) error {
+			_, _, err := DecodePAMWithOptions(r, opts)
+			return err
+		}},
+		{"DecodePFMWithOptions", "pfm", func(r io.Reader, opts ReadOptions) error {
+			_, _, err := DecodePFMWithOptions(r, opts)
+			return err
+		}},
+		{"DecodePackedPBMWithOptions", "pbm", func(r io.Reader, opts ReadOptions) error {
+			_, _, err := DecodePackedPBMWithOptions(r, opts)
+			return err
+		}},
+		{"ProbeWithOptions", "pam", func(r io.Reader, opts ReadOptions) error {
+			_, err := ProbeWithOptions(r, opts)
+			return err
+		}},
+		{"DecodeConfigWithOptions", "ppm", func(r io.Reader, opts ReadOptions) error {
+			_, err := DecodeConfigWithOptions(r, opts)
+			return err
+		}},
+	}
+	small := Limits{MaxPixels: 15, MaxCommentLength: 4}
+	for _, d := range decoders {
+		if err := d.decode(strings.NewReader(encoded[d.image]), ReadOptions{}); err != nil {
+			t.Errorf("%s with DefaultLimits: %v", d.name, err)
+		}
+		if err := d.decode(strings.NewReader(encoded[d.image]), ReadOptions{Limits: &small}); !errors.Is(err, ErrLimit) {
+			t.Errorf("%s with small limits: got %v, want ErrLimit", d.name, err)
+		}
+	}
+
+	d := NewDecoder(strings.NewReader(encoded["pam"] + encoded["ppm"]))
+	if _, _, err := d.Decode(); err != nil {
+		t.Fatalf("Decoder with DefaultLimits: %v", err)
+	}
+	d.Limits = &small
+	if _, _, err := d.Decode(); !errors.Is(err, ErrLimit) {
+		t.Fatalf("Decoder with small limits: got %v, want ErrLimit", err)
+	}
+}
@@ -0,0 +1,99 @@
+package netpbm
+
+import (
+	"io"
+	"strconv"
+)
+
+// Mode choisit la réaction des lecteurs à un fichier mal formé dont les données restent lisibles.
+type Mode int
+
+const (
+	// Strict rejette les données tronquées, les échantillons invalides ou supérieurs à la valeur
+	// maximale et les données qui suivent l'image. C'est le mode par défaut.
+	Strict Mode = iota
+	// Lenient corrige ces anomalies et les signale comme avertissements : les échantillons
+	// manquants ou invalides valent 0 (blanc pour PBM), ceux qui dépassent la valeur maximale
+	// sont ramenés à celle-ci et les données qui suivent l'image sont ignorées.
+	Lenient
+)
+
+// String renvoie le nom du mode ("strict" ou "lenient").
+func (m Mode) String() string {
+	switch m {
+	case Strict:
+		return "strict"
+	case Lenient:
+		return "lenient"
+	}
+	return "Mode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// ReadOptions règle la lecture d'une image par DecodeWithOptions, ReadPBMWithOptions,
+// DecodePGMWithOptions, ProbeWithOptions, etc. Sa valeur nulle donne une lecture stricte
+// dans les limites DefaultLimits.
+type ReadOptions struct {
+	// Mode choisit entre lecture stricte (valeur par défaut) et lecture tolérante.
+	Mode Mode
+	// Limits borne les ressources que peut exiger l'image ; nil désigne DefaultLimits.
+	Limits *Limits
+}
+
+// newHeaderReaderWith crée un headerReader lisant depuis r selon le mode et les limites de opts.
+func newHeaderReaderWith(r io.Reader, opts ReadOptions) *headerReader {
+	h := newHeaderReader(r)
+	h.setOptions(opts)
+	return h
+}
+
+// setOptions applique le mode et les limites de opts aux lectures suivantes.
+func (h *headerReader) setOptions(opts ReadOptions) {
+	h.mode, h.limits = opts.Mode, DefaultLimits
+	if opts.Limits != nil {
+		h.limits = *opts.Limits
+	}
+}
+
+// tolerate renvoie err en mode Strict ; en mode Lenient, il conserve err comme avertissement
+// et renvoie nil pour que la lecture continue.
+func (h *headerReader) tolerate(err *FormatError) error {
+	if h.mode != Lenient {
+		return err
+	}
+	h.warnings = append(h.warnings, err)
+	return nil
+}
+
+// takeWarnings renvoie les avertissements accumulés jusqu'ici et les oublie.
+func (h *headerReader) takeWarnings() []*FormatError {
+	warnings := h.warnings
+	h.warnings = nil
+	return warnings
+}
+
+// checkEnd vérifie que seuls des blancs et des commentaires suivent l'image. Seule la fin du
+// fichier termine la vérification ; une erreur de lecture ou un commentaire trop long est renvoyé.
+func (h *headerReader) checkEnd() error {
+	for {
+		c, err := h.readByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return h.readError(err, "end of image", "end of file", "")
+		}
+		if c == '#' {
+			err := h.readComment()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return h.readError(err, "end of image", "end of file", "")
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return h.tolerate(&FormatError{Offset: h.offset - 1, Line: int(h.line), Field: "end of image", Expected: "end of file", Got: strconv.Quote(string([]byte{c})), Err: ErrTrailingData})
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package netpbm
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// TestReadModes vérifie, pour chaque lecteur, qu'une anomalie est une erreur en mode Strict
+// et un avertissement en mode Lenient.
+func TestReadModes(t *testing.T) {
+	decoders := map[string]func(r io.Reader, opts ReadOptions) ([]*FormatError, error){
+		"PBM": func(r io.Reader, opts ReadOptions) ([]*FormatError, error) {
+			_, warnings, err := DecodePBMWithOptions(r, opts)
+			return warnings, err
+		},
+		"PGM": func(r io.Reader, opts ReadOptions) ([]*FormatError, error) {
+			_, warnings, err := DecodePGMWithOptions(r, opts)
+			return warnings, err
+		},
+		"PPM": func(r io.Reader, opts ReadOptions) ([]*FormatError, error) {
+			_, warnings, err := DecodePPMWithOptions(r, opts)
+			return warnings, err
+		},
+	}
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   error
+	}{
+		{"P1 short raster", "PBM", "P1 2 2 1 0 1", ErrTruncated},
+		{"P4 short raster", "PBM", "P4 9 2 \xff\x80", ErrTruncated},
+		{"P1 trailing junk", "PBM", "P1 2 1 1 0 junk", ErrTrailingData},
+		{"P2 short raster", "PGM", "P2 2 2 10 1 2 3", ErrTruncated},
+		{"P5 short raster", "PGM", "P5 2 2 10 \x01\x02\x03", ErrTruncated},
+		{"P2 above maxval", "PGM", "P2 2 1 10 1 11", ErrBadSample},
+		{"P5 above maxval", "PGM", "P5 2 1 10 \x01\x0b", ErrBadSample},
+		{"P2 trailing junk", "PGM", "P2 2 1 10 1 2 junk", ErrTrailingData},
+		{"P3 short raster", "PPM", "P3 1 2 10 1 2 3 4", ErrTruncated},
+		{"P6 short raster", "PPM", "P6 1 1 10 \x01\x02", ErrTruncated},
+		{"P3 above maxval", "PPM", "P3 1 1 10 1 11 3", ErrBadSample},
+		{"P6 above maxval", "PPM", "P6 1 1 10 \x01\x0b\x03", ErrBadSample},
+		{"P6 trailing junk", "PPM", "P6 1 1 10 \x01\x02\x03junk", ErrTrailingData},
+	}
+	for _, tt := range tests {
+		decode := decoders[tt.format]
+
+		warnings, err := decode(strings.NewReader(tt.input), ReadOptions{Mode: Strict})
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("%s: strict: got error %v, want %v", tt.name, err, tt.want)
+		}
+		if warnings != nil {
+			t.Fatalf("%s: strict: got warnings %v, want none", tt.name, warnings)
+		}
+
+		warnings, err = decode(strings.NewReader(tt.input), ReadOptions{Mode: Lenient})
+		if err != nil {
+			t.Fatalf("%s: lenient: got error %v, want none", tt.name, err)
+		}
+		if len(warnings) != 1 || !errors.Is(warnings[0], tt.want) {
+			t.Fatalf("%s: lenient: got warnings %v, want one %v", tt.name, warnings, tt.want)
+		}
+	}
+}
+
+// TestLenientRecovery vérifie les valeurs retenues en mode Lenient : échantillons manquants
+// à 0, échantillons trop grands ramenés à la valeur maximale.
+func TestLenientRecovery(t *testing.T) {
+	pgm, _, err := DecodePGMWithOptions(strings.NewReader("P2 3 1 10 11 4"), ReadOptions{Mode: Lenient})
+	if err != nil {
+		t.Fatalf("DecodePGMWithOptions: %v", err)
+	}
+	for x, want := range []uint16{10, 4, 0} {
+		if got := pgm.GrayAt(x, 0); got != want {
+			t.Fatalf("GrayAt(%d, 0) = %d, want %d", x, got, want)
+		}
+	}
+}
+
+// TestCheckEndErrors vérifie que la fin de fichier est seule à terminer sans erreur la lecture de
+// ce qui suit l'image : un commentaire trop long ou une erreur de lecture est rapporté, quel que
+// soit le mode.
+func TestCheckEndErrors(t *testing.T) {
+	limits := Limits{MaxCommentLength: 8}
+	errRead := errors.New("read failed")
+	tests := []struct {
+		name string
+		r    func() io.Reader
+		want error
+	}{
+		{"comment at end of file", func() io.Reader { return strings.NewReader("P2 1 1 255 0\n# end") }, nil},
+		{"long comment", func() io.Reader { return strings.NewReader("P2 1 1 255 0\n# a long comment\n") }, ErrLimit},
+		{"read error", func() io.Reader {
+			return io.MultiReader(strings.NewReader("P2 1 1 255 0\n"), iotest.ErrReader(errRead))
+		}, errRead},
+		{"read error in comment", func() io.Reader {
+			return io.MultiReader(strings.NewReader("P2 1 1 255 0\n# end"), iotest.ErrReader(errRead))
+		}, errRead},
+	}
+	for _, tt := range tests {
+		for _, mode := range []Mode{Strict, Lenient} {
+			_, _, err := DecodePGMWithOptions(tt.r(), ReadOptions{Mode: mode, Limits: &limits})
+			if tt.want == nil && err != nil {
+				t.Errorf("%s: %s: got error %v, want none", tt.name, mode, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("%s: %s: got error %v, want %v", tt.name, mode, err, tt.want)
+			}
+		}
+	}
+}
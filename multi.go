@@ -0,0 +1,120 @@
+package netpbm
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Decoder lit les images successives d'un flux netpbm : la norme permet de concaténer
+// plusieurs images, éventuellement de formats différents, dans un même fichier.
+type Decoder struct {
+	// Limits borne les ressources que peut exiger chaque image ; nil désigne DefaultLimits,
+	// comme pour ReadOptions.Limits.
+	Limits *Limits
+	// Mode choisit entre lecture stricte (valeur par défaut) et lecture tolérante. Les données qui
+	// suivent une image étant l'image suivante, elles ne sont jamais considérées comme anomalie.
+	Mode Mode
+
+	hr       *headerReader
+	err      error
+	warnings []*FormatError
+}
+
+// NewDecoder crée un Decoder lisant depuis r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{hr: newHeaderReader(r)}
+}
+
+// Decode lit l'image suivante du flux et renvoie son format. Il renvoie io.EOF lorsque
+// le flux ne contient plus d'image ; après une erreur, tous les appels suivants la renvoient.
+func (d *Decoder) Decode() (Image, Format, error) {
+	if d.err != nil {
+		return nil, 0, d.err
+	}
+	d.hr.setOptions(ReadOptions{Mode: d.Mode, Limits: d.Limits})
+	d.hr.comments, d.hr.commentBytes, d.hr.headerDone = nil, 0, false
+	d.hr.warnings, d.warnings = nil, nil
+	d.hr.truncated = false
+	magicNumber, err := d.hr.token()
+	if err == io.EOF && magicNumber == "" {
+		d.err = io.EOF
+		return nil, 0, io.EOF
+	}
+	if err != nil {
+		d.err = d.hr.readError(err, "magic number", "netpbm magic number", "")
+		return nil, 0, d.err
+	}
+	img, format, err := decodeImageData(d.hr, magicNumber)
+	if err != nil {
+		d.err = err
+		return nil, 0, err
+	}
+	d.warnings = d.hr.takeWarnings()
+	return img, format, nil
+}
+
+// Warnings renvoie les anomalies corrigées en mode Lenient lors du dernier appel à Decode.
+func (d *Decoder) Warnings() []*FormatError {
+	return d.warnings
+}
+
+// Encoder écrit des images les unes à la suite des autres dans un même flux.
+type Encoder struct {
+	w io.Writer
+	n int
+}
+
+// NewEncoder crée un Encoder écrivant dans w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode ajoute img à la fin du flux, dans son propre format.
+func (e *Encoder) Encode(img Image) error {
+	if err := img.Encode(e.w); err != nil {
+		return err
+	}
+	e.n++
+	return nil
+}
+
+// Count renvoie le nombre d'images écrites.
+func (e *Encoder) Count() int {
+	return e.n
+}
+
+// ReadAll lit toutes les images d'un fichier netpbm.
+func ReadAll(filename string) ([]Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var images []Image
+	d := NewDecoder(file)
+	for {
+		img, _, err := d.Decode()
+		if err == io.EOF {
+			return images, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+}
+
+// AppendFile ajoute img à la fin du fichier filename, qui est créé s'il n'existe pas.
+func AppendFile(filename string, img Image) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	if err := img.Encode(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,341 @@
+package netpbm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// wordBits est le nombre de pixels stockés dans un mot de PackedPBM.
+const wordBits = 64
+
+// PackedPBM est une image PBM stockée à raison d'un bit par pixel, comme dans un fichier P4 :
+// huit fois moins de mémoire qu'un PBM. Chaque ligne occupe Stride mots de 64 bits, le pixel
+// x de la ligne étant le bit 63-x%64 du mot x/64 (bit à 1 pour un pixel noir). Les bits de
+// remplissage en fin de ligne sont toujours à zéro.
+type PackedPBM struct {
+	Words         []uint64
+	Width, Height int
+	Stride        int
+	MagicNumber   string
+	Comments      []string
+	Compact       bool // écrire les chiffres d'une image P1 sans blanc entre eux
+}
+
+// NewPackedPBM crée une image PBM compacte blanche de la taille donnée, au format binaire "P4".
+func NewPackedPBM(width, height int) *PackedPBM {
+	stride := (width + wordBits - 1) / wordBits
+	return &PackedPBM{
+		Words:       make([]uint64, stride*height),
+		Width:       width,
+		Height:      height,
+		Stride:      stride,
+		MagicNumber: "P4",
+	}
+}
+
+// ReadPackedPBM lit une image PBM à partir d'un fichier et la stocke à raison d'un bit par pixel.
+// La lecture est stricte : voir ReadOptions et Strict.
+func ReadPackedPBM(filename string) (*PackedPBM, error) {
+	packed, _, err := ReadPackedPBMWithOptions(filename, ReadOptions{})
+	return packed, err
+}
+
+// ReadPackedPBMWithOptions lit une image PBM compacte depuis un fichier selon opts et, en mode
+// Lenient, renvoie les anomalies corrigées.
+func ReadPackedPBMWithOptions(filename string, opts ReadOptions) (*PackedPBM, []*FormatError, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	return DecodePackedPBMWithOptions(file, opts)
+}
+
+// DecodePackedPBM lit une image PBM depuis r et la stocke à raison d'un bit par pixel.
+// La lecture est stricte et consomme r jusqu'au bout ; un Decoder lit les flux de plusieurs images.
+func DecodePackedPBM(r io.Reader) (*PackedPBM, error) {
+	packed, _, err := DecodePackedPBMWithOptions(r, ReadOptions{})
+	return packed, err
+}
+
+// DecodePackedPBMWithOptions lit une image PBM compacte depuis r selon opts et, en mode Lenient,
+// renvoie les anomalies corrigées. r est lu jusqu'au bout pour détecter les données en trop.
+func DecodePackedPBMWithOptions(r io.Reader, opts ReadOptions) (*PackedPBM, []*FormatError, error) {
+	hr := newHeaderReaderWith(r, opts)
+
+	// Lire le numéro magique
+	magicNumber, err := hr.magic("P1 or P4", func(m string) bool { return m == "P1" || m == "P4" })
+	if err != nil {
+		return nil, nil, err
+	}
+
+	packed, err := decodePackedPBM(hr, magicNumber)
+	if err == nil {
+		err = hr.checkEnd()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return packed, hr.takeWarnings(), nil
+}
+
+// decodePackedPBM lit la suite d'une image PBM compacte dont le numéro magique a déjà été lu.
+func decodePackedPBM(hr *headerReader, magicNumber string) (*PackedPBM, error) {
+	// Lire la largeur et la hauteur (les commentaires sont conservés)
+	width, height, err := hr.dimensions()
+	if err != nil {
+		return nil, err
+	}
+	if err := hr.checkSize(width, height, 1, 1); err != nil {
+		return nil, err
+	}
+
+	// lire les données
+	packed := NewPackedPBM(width, height)
+	packed.MagicNumber = magicNumber
+	packed.Comments = hr.takeComments()
+	if magicNumber == "P4" {
+		buf := make([]byte, 8*packed.Stride)
+		err = readPBMRaw(hr, width, height, func(y int, row []byte) {
+			copy(buf, row)
+			packed.setRowBytes(y, buf)
+		})
+	} else {
+		err = readPBMPlain(hr, width, height, func(x, y int) { packed.Set(x, y, true) })
+	}
+	if err != nil {
+		return nil, err
+	}
+	return packed, nil
+}
+
+// setRowBytes remplit la ligne y à partir de buf, qui contient 8*Stride octets au format P4 ;
+// les bits au-delà de Width sont ignorés.
+func (p *PackedPBM) setRowBytes(y int, buf []byte) {
+	row := p.row(y)
+	for i := range row {
+		row[i] = binary.BigEndian.Uint64(buf[8*i:])
+	}
+	if len(row) > 0 {
+		row[len(row)-1] &= p.lastWordMask()
+	}
+}
+
+// row renvoie les mots de la ligne y, qui partagent la mémoire de l'image.
+func (p *PackedPBM) row(y int) []uint64 {
+	return p.Words[y*p.Stride : (y+1)*p.Stride]
+}
+
+// lastWordMask renvoie le masque des bits utiles du dernier mot de chaque ligne.
+func (p *PackedPBM) lastWordMask() uint64 {
+	if p.Width%wordBits == 0 {
+		return ^uint64(0)
+	}
+	return ^uint64(0) << uint(wordBits-p.Width%wordBits)
+}
+
+// Size retourne la largeur et la hauteur de l'image.
+func (p *PackedPBM) Size() (int, int) {
+	return p.Width, p.Height
+}
+
+// BitAt retourne la valeur du pixel a (x, y) : true pour un pixel noir, false hors de l'image.
+func (p *PackedPBM) BitAt(x, y int) bool {
+	if x < 0 || x >= p.Width || y < 0 || y >= p.Height {
+		return false
+	}
+	return p.Words[y*p.Stride+x/wordBits]&(1<<uint(wordBits-1-x%wordBits)) != 0
+}
+
+// Set définit la valeur du pixel (x, y) : true pour un pixel noir ; les coordonnées invalides sont ignorées.
+func (p *PackedPBM) Set(x, y int, value bool) {
+	if x < 0 || x >= p.Width || y < 0 || y >= p.Height {
+		return
+	}
+	bit := uint64(1) << uint(wordBits-1-x%wordBits)
+	if value {
+		p.Words[y*p.Stride+x/wordBits] |= bit
+	} else {
+		p.Words[y*p.Stride+x/wordBits] &^= bit
+	}
+}
+
+// Pack convertit l'image PBM en image compacte d'un bit par pixel.
+func (pbm *PBM) Pack() *PackedPBM {
+	p := NewPackedPBM(pbm.Width, pbm.Height)
+	p.MagicNumber = pbm.MagicNumber
+	p.Comments = append([]string(nil), pbm.Comments...)
+	p.Compact = pbm.Compact
+	for y := 0; y < pbm.Height; y++ {
+		for x, pixel := range pbm.Row(y) {
+			if pixel {
+				p.Set(x, y, true)
+			}
+		}
+	}
+	return p
+}
+
+// Unpack convertit l'image compacte en PBM d'un booléen par pixel.
+func (p *PackedPBM) Unpack() *PBM {
+	pbm := NewPBM(p.Width, p.Height)
+	pbm.MagicNumber = p.MagicNumber
+	pbm.Comments = append([]string(nil), p.Comments...)
+	pbm.Compact = p.Compact
+	for y := 0; y < p.Height; y++ {
+		row := pbm.Row(y)
+		for x := range row {
+			row[x] = p.BitAt(x, y)
+		}
+	}
+	return pbm
+}
+
+// Save enregistre l'image exactement dans le fichier filename, en l'écrasant s'il existe.
+func (p *PackedPBM) Save(filename string) error {
+	_, err := p.SaveWithOptions(filename, SaveOptions{})
+	return err
+}
+
+// SaveWithOptions enregistre l'image selon opts et renvoie le chemin du fichier réellement écrit.
+func (p *PackedPBM) SaveWithOptions(filename string, opts SaveOptions) (string, error) {
+	return saveFile(filename, opts, func() error { return validatePBM(p.MagicNumber) }, p.Encode)
+}
+
+// Encode écrit l'image dans w au format PBM.
+func (p *PackedPBM) Encode(w io.Writer) error {
+	buf := make([]byte, 8*p.Stride)
+	return encodePBM(w, p.MagicNumber, p.Comments, p.Compact, p.Width, p.Height, p.BitAt, func(y int, packed []byte) {
+		for i, word := range p.row(y) {
+			binary.BigEndian.PutUint64(buf[8*i:], word)
+		}
+		copy(packed, buf)
+	})
+}
+
+// Invert inverse les couleurs de chaque pixel, un mot de 64 pixels à la fois.
+func (p *PackedPBM) Invert() {
+	if p.Stride == 0 {
+		return
+	}
+	mask := p.lastWordMask()
+	for y := 0; y < p.Height; y++ {
+		row := p.row(y)
+		for i := range row {
+			row[i] = ^row[i]
+		}
+		row[len(row)-1] &= mask
+	}
+}
+
+// FlipH retourne l'image horizontalement (miroir gauche-droite).
+func (p *PackedPBM) FlipH() {
+	if p.Stride == 0 {
+		return
+	}
+	// après inversion des mots et de leurs bits, le remplissage se retrouve en tête de ligne
+	shift := uint(p.Stride*wordBits - p.Width)
+	for y := 0; y < p.Height; y++ {
+		row := p.row(y)
+		for i, j := 0, len(row)-1; i <= j; i, j = i+1, j-1 {
+			row[i], row[j] = bits.Reverse64(row[j]), bits.Reverse64(row[i])
+		}
+		if shift == 0 {
+			continue
+		}
+		for i := range row {
+			row[i] <<= shift
+			if i+1 < len(row) {
+				row[i] |= row[i+1] >> (wordBits - shift)
+			}
+		}
+	}
+}
+
+// FlipV retourne l'image verticalement (miroir haut-bas).
+func (p *PackedPBM) FlipV() {
+	for y := 0; y < p.Height/2; y++ {
+		top, bottom := p.row(y), p.row(p.Height-1-y)
+		for i := range top {
+			top[i], bottom[i] = bottom[i], top[i]
+		}
+	}
+}
+
+// FlipAndFlop retourne l'image horizontalement puis verticalement.
+func (p *PackedPBM) FlipAndFlop() {
+	p.FlipH()
+	p.FlipV()
+}
+
+// combine applique op mot à mot entre p et other, qui doivent avoir la même taille.
+func (p *PackedPBM) combine(other *PackedPBM, op func(a, b uint64) uint64) error {
+	if other.Width != p.Width || other.Height != p.Height {
+		return fmt.Errorf("image size %dx%d does not match %dx%d", other.Width, other.Height, p.Width, p.Height)
+	}
+	for i := range p.Words {
+		p.Words[i] = op(p.Words[i], other.Words[i])
+	}
+	return nil
+}
+
+// And ne laisse noirs que les pixels noirs à la fois dans p et dans other.
+func (p *PackedPBM) And(other *PackedPBM) error {
+	return p.combine(other, func(a, b uint64) uint64 { return a & b })
+}
+
+// Or rend noirs les pixels noirs dans p ou dans other.
+func (p *PackedPBM) Or(other *PackedPBM) error {
+	return p.combine(other, func(a, b uint64) uint64 { return a | b })
+}
+
+// Xor rend noirs les pixels noirs dans exactement une des deux images.
+func (p *PackedPBM) Xor(other *PackedPBM) error {
+	return p.combine(other, func(a, b uint64) uint64 { return a ^ b })
+}
+
+// PopCount renvoie le nombre de pixels noirs de l'image.
+func (p *PackedPBM) PopCount() int {
+	n := 0
+	for _, word := range p.Words {
+		n += bits.OnesCount64(word)
+	}
+	return n
+}
+
+// Channels renvoie le nombre d'échantillons par pixel, toujours 1 (Image).
+func (p *PackedPBM) Channels() int {
+	return 1
+}
+
+// MaxValue renvoie la valeur maximale d'un échantillon, toujours 1 (Image).
+func (p *PackedPBM) MaxValue() uint {
+	return 1
+}
+
+// Sample renvoie l'échantillon du pixel (x, y) (Image) : 0 pour un pixel noir, 1 pour un blanc.
+func (p *PackedPBM) Sample(x, y, c int) uint16 {
+	if c != 0 || x < 0 || x >= p.Width || y < 0 || y >= p.Height || p.BitAt(x, y) {
+		return 0
+	}
+	return 1
+}
+
+// SetSample définit l'échantillon du pixel (x, y) : 0 pour noir, toute autre valeur pour blanc (Image).
+func (p *PackedPBM) SetSample(x, y, c int, value uint16) {
+	if c == 0 {
+		p.Set(x, y, value == 0)
+	}
+}
+
+// Clone renvoie une copie indépendante de l'image (Image).
+func (p *PackedPBM) Clone() Image {
+	clone := *p
+	clone.Words = append([]uint64(nil), p.Words...)
+	clone.Comments = append([]string(nil), p.Comments...)
+	return &clone
+}
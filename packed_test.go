@@ -0,0 +1,124 @@
+package netpbm
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// packedWidths entoure les multiples de 64, où commencent et finissent les mots d'une ligne.
+var packedWidths = []int{1, 2, 63, 64, 65, 127, 128, 129, 130, 191, 192, 193}
+
+// randomPBM renvoie une image PBM aux pixels tirés au hasard.
+func randomPBM(rng *rand.Rand, width, height int) *PBM {
+	pbm := NewPBM(width, height)
+	for y := 0; y < height; y++ {
+		row := pbm.Row(y)
+		for x := range row {
+			row[x] = rng.Intn(2) == 1
+		}
+	}
+	return pbm
+}
+
+// checkPacked vérifie que p a les mêmes pixels que want, que ses bits de remplissage sont à
+// zéro, que PopCount compte ses pixels noirs et qu'il s'encode comme want en P1 et en P4.
+func checkPacked(t *testing.T, what string, p *PackedPBM, want *PBM) {
+	t.Helper()
+	black := 0
+	for y := 0; y < want.Height; y++ {
+		for x := 0; x < want.Width; x++ {
+			if p.BitAt(x, y) != want.BitAt(x, y) {
+				t.Fatalf("%s, width %d: pixel (%d, %d) = %v, want %v", what, want.Width, x, y, p.BitAt(x, y), want.BitAt(x, y))
+			}
+			if want.BitAt(x, y) {
+				black++
+			}
+		}
+		if last := p.row(y)[p.Stride-1]; last&^p.lastWordMask() != 0 {
+			t.Fatalf("%s, width %d: row %d has padding bits set: %064b", what, want.Width, y, last)
+		}
+	}
+	if n := p.PopCount(); n != black {
+		t.Fatalf("%s, width %d: PopCount = %d, want %d", what, want.Width, n, black)
+	}
+	for _, magicNumber := range []string{"P1", "P4"} {
+		p.MagicNumber, want.MagicNumber = magicNumber, magicNumber
+		var got, expected bytes.Buffer
+		if err := p.Encode(&got); err != nil {
+			t.Fatalf("%s, width %d: %s: %v", what, want.Width, magicNumber, err)
+		}
+		if err := want.Encode(&expected); err != nil {
+			t.Fatalf("%s, width %d: %s: %v", what, want.Width, magicNumber, err)
+		}
+		if !bytes.Equal(got.Bytes(), expected.Bytes()) {
+			t.Fatalf("%s, width %d: %s encoding differs from PBM", what, want.Width, magicNumber)
+		}
+	}
+}
+
+// TestPackedTransforms compare Invert, FlipH, FlipV et leurs enchaînements sur une image
+// compacte et sur la même image PBM.
+func TestPackedTransforms(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	transforms := []struct {
+		name   string
+		pbm    func(*PBM)
+		packed func(*PackedPBM)
+	}{
+		{"Invert", (*PBM).Invert, (*PackedPBM).Invert},
+		{"FlipH", func(pbm *PBM) { pbm.FlipH() }, (*PackedPBM).FlipH},
+		{"FlipV", func(pbm *PBM) { pbm.FlipV() }, (*PackedPBM).FlipV},
+		{"Invert+FlipH", func(pbm *PBM) { pbm.Invert(); pbm.FlipH() }, func(p *PackedPBM) { p.Invert(); p.FlipH() }},
+		{"FlipH+Invert", func(pbm *PBM) { pbm.FlipH(); pbm.Invert() }, func(p *PackedPBM) { p.FlipH(); p.Invert() }},
+		{"FlipAndFlop", (*PBM).FlipAndFlop, (*PackedPBM).FlipAndFlop},
+	}
+	for _, width := range packedWidths {
+		for _, tr := range transforms {
+			pbm := randomPBM(rng, width, 3)
+			p := pbm.Pack()
+			checkPacked(t, "Pack", p, pbm)
+			tr.pbm(pbm)
+			tr.packed(p)
+			checkPacked(t, tr.name, p, pbm)
+			checkPacked(t, tr.name+" Unpack", p.Unpack().Pack(), pbm)
+		}
+	}
+}
+
+// TestPackedCombine compare And, Or et Xor à leur calcul pixel par pixel, y compris après
+// une inversion qui ne doit pas avoir noirci le remplissage.
+func TestPackedCombine(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	ops := []struct {
+		name    string
+		combine func(p, other *PackedPBM) error
+		pixel   func(a, b bool) bool
+	}{
+		{"And", (*PackedPBM).And, func(a, b bool) bool { return a && b }},
+		{"Or", (*PackedPBM).Or, func(a, b bool) bool { return a || b }},
+		{"Xor", (*PackedPBM).Xor, func(a, b bool) bool { return a != b }},
+	}
+	for _, width := range packedWidths {
+		for _, op := range ops {
+			a, b := randomPBM(rng, width, 3), randomPBM(rng, width, 3)
+			b.Invert()
+			pa, pb := a.Pack(), b.Pack()
+			pb.Invert()
+			pb.Invert()
+			if err := op.combine(pa, pb); err != nil {
+				t.Fatalf("%s, width %d: %v", op.name, width, err)
+			}
+			want := NewPBM(width, 3)
+			for y := 0; y < 3; y++ {
+				for x := 0; x < width; x++ {
+					want.Set(x, y, op.pixel(a.BitAt(x, y), b.BitAt(x, y)))
+				}
+			}
+			checkPacked(t, op.name, pa, want)
+		}
+	}
+	if err := NewPackedPBM(64, 2).Xor(NewPackedPBM(65, 2)); err == nil {
+		t.Fatal("Xor of images of different sizes succeeded")
+	}
+}
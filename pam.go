@@ -0,0 +1,443 @@
+package netpbm
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Types de tuples standard d'une image PAM.
+const (
+	TupleBlackAndWhite      = "BLACKANDWHITE"
+	TupleGrayscale          = "GRAYSCALE"
+	TupleRGB                = "RGB"
+	TupleBlackAndWhiteAlpha = "BLACKANDWHITE_ALPHA"
+	TupleGrayscaleAlpha     = "GRAYSCALE_ALPHA"
+	TupleRGBAlpha           = "RGB_ALPHA"
+)
+
+// PAM est une structure pour représenter des images PAM (P7).
+// Chaque pixel occupe Step échantillons entrelacés : Step est la profondeur (DEPTH) de l'image.
+type PAM struct {
+	Raster[uint16]
+	Max         uint
+	TupleType   string
+	MagicNumber string
+	// Comments contient les commentaires de l'en-tête, lus par DecodePAM et écrits par Encode.
+	Comments []string
+}
+
+// NewPAM crée une image PAM noire (échantillons à zéro) de la taille et du type donnés.
+func NewPAM(width, height, depth int, max uint, tupleType string) *PAM {
+	return &PAM{
+		Raster:      NewRaster[uint16](width, height, depth),
+		Max:         max,
+		TupleType:   tupleType,
+		MagicNumber: "P7",
+	}
+}
+
+// ReadPAM lit une image PAM depuis un fichier et renvoie une structure qui représente l'image.
+// La lecture est stricte : voir ReadOptions et Strict.
+func ReadPAM(filename string) (*PAM, error) {
+	pam, _, err := ReadPAMWithOptions(filename, ReadOptions{})
+	return pam, err
+}
+
+// ReadPAMWithOptions lit une image PAM depuis un fichier selon opts et, en mode Lenient, renvoie les
+// anomalies corrigées.
+func ReadPAMWithOptions(filename string, opts ReadOptions) (*PAM, []*FormatError, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	return DecodePAMWithOptions(file, opts)
+}
+
+// DecodePAM lit une image PAM depuis r et renvoie une structure qui représente l'image.
+// La lecture est stricte et consomme r jusqu'au bout ; un Decoder lit les flux de plusieurs images.
+func DecodePAM(r io.Reader) (*PAM, error) {
+	pam, _, err := DecodePAMWithOptions(r, ReadOptions{})
+	return pam, err
+}
+
+// DecodePAMWithOptions lit une image PAM depuis r selon opts et, en mode Lenient, renvoie les anomalies
+// corrigées. r est lu jusqu'au bout pour détecter les données en trop.
+func DecodePAMWithOptions(r io.Reader, opts ReadOptions) (*PAM, []*FormatError, error) {
+	hr := newHeaderReaderWith(r, opts)
+
+	// lit le numero magique
+	magicNumber, err := hr.magic("P7", func(m string) bool { return m == "P7" })
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pam, err := decodePAM(hr, magicNumber)
+	if err == nil {
+		err = hr.checkEnd()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return pam, hr.takeWarnings(), nil
+}
+
+// decodePAM lit la suite d'une image PAM dont le numéro magique a déjà été lu.
+func decodePAM(hr *headerReader, magicNumber string) (*PAM, error) {
+	header, err := readPAMHeader(hr, magicNumber)
+	if err != nil {
+		return nil, err
+	}
+	if err := hr.checkSize(header.Width, header.Height, header.Depth, 8*bytesPerSample(header.Max)); err != nil {
+		return nil, err
+	}
+
+	// lit les données (toujours binaires en P7) ligne par ligne
+	pam := NewPAM(header.Width, header.Height, header.Depth, header.Max, header.TupleType)
+	pam.Comments = header.Comments
+	buf := make([]byte, header.Width*header.Depth*bytesPerSample(header.Max))
+	for y := 0; y < header.Height; y++ {
+		if err := readRawSamples(hr, pam.Row(y), header.Max, buf); err != nil {
+			return nil, err
+		}
+	}
+	return pam, nil
+}
+
+// readPAMHeader lit les paires "MOT-CLÉ valeur" d'un en-tête PAM jusqu'à ENDHDR.
+func readPAMHeader(hr *headerReader, magicNumber string) (Header, error) {
+	var tupleTypes []string
+	width, height, depth, maxValue := -1, -1, -1, -1
+	for {
+		keyword, err := hr.token()
+		if err == nil && keyword == "" {
+			err = io.EOF
+		}
+		if err != nil {
+			return Header{}, hr.readError(err, "header", "ENDHDR", "")
+		}
+		if keyword == "ENDHDR" {
+			break
+		}
+		if keyword == "TUPLTYPE" {
+			tupleType, err := hr.restOfLine()
+			if err != nil {
+				return Header{}, hr.readError(err, "TUPLTYPE", "", "")
+			}
+			tupleTypes = append(tupleTypes, tupleType)
+			continue
+		}
+		var value int
+		switch keyword {
+		case "WIDTH":
+			value, err = hr.int("width")
+			width = value
+		case "HEIGHT":
+			value, err = hr.int("height")
+			height = value
+		case "DEPTH":
+			value, err = hr.int("depth")
+			depth = value
+		case "MAXVAL":
+			value, err = hr.int("max value")
+			maxValue = value
+		default:
+			return Header{}, hr.tokenError(ErrBadHeader, "header", "PAM keyword", strconv.Quote(keyword))
+		}
+		if err != nil {
+			return Header{}, err
+		}
+	}
+
+	// les erreurs sont situées sur ENDHDR, le dernier jeton lu
+	for _, field := range []struct {
+		name  string
+		value int
+	}{{"WIDTH", width}, {"HEIGHT", height}, {"DEPTH", depth}} {
+		if field.value == -1 {
+			return Header{}, hr.tokenError(ErrBadHeader, field.name, "positive integer", "nothing")
+		}
+		if field.value < 1 {
+			return Header{}, hr.tokenError(ErrBadHeader, field.name, "positive integer", strconv.Itoa(field.value))
+		}
+	}
+	if maxValue == -1 {
+		return Header{}, hr.tokenError(ErrBadHeader, "MAXVAL", "1 to 65535", "nothing")
+	}
+	if err := hr.checkMaxValue("MAXVAL", maxValue); err != nil {
+		return Header{}, err
+	}
+	return Header{
+		MagicNumber: magicNumber,
+		Format:      FormatPAM,
+		Width:       width,
+		Height:      height,
+		Depth:       depth,
+		Max:         uint(maxValue),
+		TupleType:   strings.Join(tupleTypes, " "),
+		Comments:    hr.takeComments(),
+	}, nil
+}
+
+// Save enregistre l'image PAM exactement dans le fichier filename, en l'écrasant s'il existe.
+func (pam *PAM) Save(filename string) error {
+	_, err := pam.SaveWithOptions(filename, SaveOptions{})
+	return err
+}
+
+// SaveWithOptions enregistre l'image PAM selon opts et renvoie le chemin du fichier réellement écrit.
+func (pam *PAM) SaveWithOptions(filename string, opts SaveOptions) (string, error) {
+	return saveFile(filename, opts, pam.validate, pam.Encode)
+}
+
+// checkTupleType vérifie qu'un type de tuple tient sur la ligne TUPLTYPE de l'en-tête.
+func checkTupleType(tupleType string) error {
+	if strings.ContainsAny(tupleType, "\r\n") {
+		return fmt.Errorf("invalid tuple type: %q", tupleType)
+	}
+	return nil
+}
+
+// validate vérifie, avant toute écriture, que l'image peut être écrite telle quelle : profondeur
+// positive, valeur maximale de 1 à 65535, type de tuple sur une ligne et aucun échantillon au-delà
+// de la valeur maximale.
+func (pam *PAM) validate() error {
+	if pam.Step < 1 {
+		return fmt.Errorf("invalid depth: %d", pam.Step)
+	}
+	if pam.Max < 1 || pam.Max > MaxValue16 {
+		return fmt.Errorf("unsupported max value: %d", pam.Max)
+	}
+	if err := checkTupleType(pam.TupleType); err != nil {
+		return err
+	}
+	for y := 0; y < pam.Height; y++ {
+		if err := checkSamples(y, pam.Row(y), pam.Max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encode écrit l'image PAM dans w.
+func (pam *PAM) Encode(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+
+	if err := pam.validate(); err != nil {
+		return err
+	}
+
+	// ecrit l'en-tête
+	if _, err := fmt.Fprint(writer, "P7\n"); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+	if err := writeComments(writer, pam.Comments); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(writer, "WIDTH %d\nHEIGHT %d\nDEPTH %d\nMAXVAL %d\n", pam.Width, pam.Height, pam.Step, pam.Max); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+	if pam.TupleType != "" {
+		if _, err := fmt.Fprintf(writer, "TUPLTYPE %s\n", pam.TupleType); err != nil {
+			return fmt.Errorf("failed to write header: %v", err)
+		}
+	}
+	if _, err := fmt.Fprint(writer, "ENDHDR\n"); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+
+	// ecrit les données
+	for y := 0; y < pam.Height; y++ {
+		if err := writeRawSamples(writer, pam.Row(y), pam.Max); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// Size renvoie la largeur et la hauteur de l'image.
+func (pam *PAM) Size() (int, int) {
+	return pam.Width, pam.Height
+}
+
+// TupleAt renvoie le tuple du pixel à la position (x, y), ou nil si les coordonnées sont invalides.
+// Le tuple partage la mémoire de l'image.
+func (pam *PAM) TupleAt(x, y int) []uint16 {
+	return pam.Elems(x, y)
+}
+
+// Set définit le tuple du pixel à la position (x, y).
+func (pam *PAM) Set(x, y int, tuple []uint16) {
+	copy(pam.Elems(x, y), tuple)
+}
+
+// Channels renvoie le nombre d'échantillons par pixel, c'est-à-dire la profondeur Step (Image).
+func (pam *PAM) Channels() int {
+	return pam.Step
+}
+
+// MaxValue renvoie la valeur maximale d'un échantillon (Image).
+func (pam *PAM) MaxValue() uint {
+	return pam.Max
+}
+
+// Sample renvoie l'échantillon du canal c du pixel (x, y) (Image).
+func (pam *PAM) Sample(x, y, c int) uint16 {
+	tuple := pam.TupleAt(x, y)
+	if c < 0 || c >= len(tuple) {
+		return 0
+	}
+	return tuple[c]
+}
+
+// SetSample définit l'échantillon du canal c du pixel (x, y) (Image).
+func (pam *PAM) SetSample(x, y, c int, value uint16) {
+	tuple := pam.TupleAt(x, y)
+	if c >= 0 && c < len(tuple) {
+		tuple[c] = value
+	}
+}
+
+// Clone renvoie une copie indépendante de l'image PAM (Image).
+func (pam *PAM) Clone() Image {
+	clone := *pam
+	clone.Raster = pam.Copy()
+	clone.Comments = append([]string(nil), pam.Comments...)
+	return &clone
+}
+
+// View renvoie la partie de l'image comprise dans r, qui partage ses échantillons avec pam :
+// modifier l'une modifie l'autre. Les coordonnées de la vue commencent à (0, 0).
+func (pam *PAM) View(r image.Rectangle) *PAM {
+	view := *pam
+	view.Raster = pam.Raster.View(r)
+	return &view
+}
+
+// HasAlpha indique si le dernier canal de l'image est un canal alpha.
+func (pam *PAM) HasAlpha() bool {
+	return strings.HasSuffix(pam.TupleType, "_ALPHA")
+}
+
+// Alpha renvoie le masque alpha de l'image sous forme de PGM, ou nil s'il n'y en a pas.
+func (pam *PAM) Alpha() *PGM {
+	if !pam.HasAlpha() {
+		return nil
+	}
+	return pam.channel(pam.Step - 1)
+}
+
+// channel renvoie le canal c de l'image sous forme de PGM.
+func (pam *PAM) channel(c int) *PGM {
+	pgm := NewPGM(pam.Width, pam.Height, pam.Max)
+	for y := 0; y < pam.Height; y++ {
+		dst := pgm.Row(y)
+		for x := range dst {
+			dst[x] = pam.TupleAt(x, y)[c]
+		}
+	}
+	return pgm
+}
+
+// AddAlpha ajoute le masque alpha donné comme canal supplémentaire, remis à l'échelle de l'image.
+func (pam *PAM) AddAlpha(alpha *PGM) error {
+	if pam.HasAlpha() {
+		return fmt.Errorf("image already has an alpha channel")
+	}
+	if alpha.Width != pam.Width || alpha.Height != pam.Height {
+		return fmt.Errorf("alpha mask size %dx%d does not match image size %dx%d", alpha.Width, alpha.Height, pam.Width, pam.Height)
+	}
+	raster := NewRaster[uint16](pam.Width, pam.Height, pam.Step+1)
+	for y := 0; y < pam.Height; y++ {
+		for x := 0; x < pam.Width; x++ {
+			tuple := raster.Elems(x, y)
+			copy(tuple, pam.TupleAt(x, y))
+			tuple[pam.Step] = scaleSample(alpha.GrayAt(x, y), alpha.Max, pam.Max)
+		}
+	}
+	pam.Raster = raster
+	pam.TupleType += "_ALPHA"
+	return nil
+}
+
+// colorChannels renvoie le nombre de canaux de couleur, sans l'éventuel canal alpha.
+func (pam *PAM) colorChannels() int {
+	if pam.HasAlpha() {
+		return pam.Step - 1
+	}
+	return pam.Step
+}
+
+// ToPPM convertit l'image PAM en PPM ; le canal alpha est ignoré.
+func (pam *PAM) ToPPM() *PPM {
+	ppm := NewPPM(pam.Width, pam.Height, pam.Max)
+	channels := pam.colorChannels()
+	for y := 0; y < pam.Height; y++ {
+		row := ppm.Row(y)
+		for x := range row {
+			tuple := pam.TupleAt(x, y)
+			if channels >= 3 {
+				row[x] = Pixel{tuple[0], tuple[1], tuple[2]}
+			} else {
+				row[x] = Pixel{tuple[0], tuple[0], tuple[0]}
+			}
+		}
+	}
+	return ppm
+}
+
+// ToPGM convertit l'image PAM en PGM ; le canal alpha est ignoré.
+func (pam *PAM) ToPGM() *PGM {
+	if pam.colorChannels() >= 3 {
+		return pam.ToPPM().ToPGM()
+	}
+	return pam.channel(0)
+}
+
+// ToPBM convertit l'image PAM en PBM ; le canal alpha est ignoré.
+func (pam *PAM) ToPBM() *PBM {
+	pbm := pam.ToPGM().ToPBM()
+	pbm.MagicNumber = "P4"
+	return pbm
+}
+
+// ToPAM convertit l'image PBM en PAM de type BLACKANDWHITE (0 = noir, 1 = blanc).
+func (pbm *PBM) ToPAM() *PAM {
+	pam := NewPAM(pbm.Width, pbm.Height, 1, 1, TupleBlackAndWhite)
+	for y := 0; y < pbm.Height; y++ {
+		dst := pam.Row(y)
+		for x, black := range pbm.Row(y) {
+			if !black {
+				dst[x] = 1
+			}
+		}
+	}
+	return pam
+}
+
+// ToPAM convertit l'image PGM en PAM de type GRAYSCALE.
+func (pgm *PGM) ToPAM() *PAM {
+	pam := NewPAM(pgm.Width, pgm.Height, 1, pgm.Max, TupleGrayscale)
+	for y := 0; y < pgm.Height; y++ {
+		copy(pam.Row(y), pgm.Row(y))
+	}
+	return pam
+}
+
+// ToPAM convertit l'image PPM en PAM de type RGB.
+func (ppm *PPM) ToPAM() *PAM {
+	pam := NewPAM(ppm.Width, ppm.Height, 3, ppm.Max, TupleRGB)
+	for y := 0; y < ppm.Height; y++ {
+		dst := pam.Row(y)
+		for x, p := range ppm.Row(y) {
+			dst[3*x], dst[3*x+1], dst[3*x+2] = p.R, p.G, p.B
+		}
+	}
+	return pam
+}
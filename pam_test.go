@@ -0,0 +1,24 @@
+package netpbm
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestPAMEncodeInvalid vérifie que Encode et NewRowWriter refusent un en-tête PAM illisible.
+func TestPAMEncodeInvalid(t *testing.T) {
+	zeroMax := NewPAM(1, 1, 1, 0, "")
+	injected := NewPAM(1, 1, 1, 255, "GRAYSCALE\nENDHDR")
+	for _, pam := range []*PAM{zeroMax, injected} {
+		var buf bytes.Buffer
+		if err := pam.Encode(&buf); err == nil {
+			t.Fatalf("Encode accepted MAXVAL %d, TUPLTYPE %q", pam.Max, pam.TupleType)
+		}
+	}
+
+	var buf bytes.Buffer
+	header := Header{MagicNumber: "P7", Width: 1, Height: 1, Depth: 1, Max: 255, TupleType: "GRAYSCALE\nENDHDR"}
+	if _, err := NewRowWriter(&buf, header); err == nil {
+		t.Fatalf("NewRowWriter accepted TUPLTYPE %q", header.TupleType)
+	}
+}
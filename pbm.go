@@ -3,208 +3,350 @@ package netpbm
 import (
 	"bufio"
 	"fmt"
+	"image"
+	"io"
 	"os"
 	"strconv"
-	"strings"
-	"time"
 )
 
 // PBM est une structure pour représenter des images PBM.
+// Chaque pixel est un booléen : true pour un pixel noir.
 type PBM struct {
-	Data          [][]bool
-	Width, Height int
-	MagicNumber   string
+	Raster[bool]
+	MagicNumber string
+	// Comments contient les commentaires de l'en-tête, lus par DecodePBM et écrits par Encode.
+	Comments []string
+	// Compact demande à Encode d'écrire les chiffres d'une image P1 sans blanc entre eux.
+	Compact bool
+}
+
+// NewPBM crée une image PBM blanche de la taille donnée, au format binaire "P4".
+func NewPBM(width, height int) *PBM {
+	return &PBM{Raster: NewRaster[bool](width, height, 1), MagicNumber: "P4"}
 }
 
 // ReadPBM lit une image PBM à partir d'un fichier et renvoie une structure représentant l'image.
+// Elle est stricte : voir ReadOptions et Strict.
 func ReadPBM(filename string) (*PBM, error) {
+	pbm, _, err := ReadPBMWithOptions(filename, ReadOptions{})
+	return pbm, err
+}
+
+// ReadPBMWithOptions lit une image PBM à partir d'un fichier selon opts et renvoie, en mode
+// Lenient, les anomalies corrigées.
+func ReadPBMWithOptions(filename string, opts ReadOptions) (*PBM, []*FormatError, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	return DecodePBMWithOptions(file, opts)
+}
 
-	// Lire le numéro magique
-	if !scanner.Scan() {
-		return nil, fmt.Errorf("échec de la lecture du numéro magique")
-	}
-	magicNumber := scanner.Text()
+// DecodePBM lit une image PBM depuis r et renvoie une structure représentant l'image.
+// Elle est stricte et lit r jusqu'au bout ; pour un flux de plusieurs images, utiliser Decoder.
+func DecodePBM(r io.Reader) (*PBM, error) {
+	pbm, _, err := DecodePBMWithOptions(r, ReadOptions{})
+	return pbm, err
+}
 
-	if magicNumber != "P1" && magicNumber != "P4" {
-		return nil, fmt.Errorf("format PBM non pris en charge : %s", magicNumber)
-	}
+// DecodePBMWithOptions lit une image PBM depuis r selon opts et renvoie, en mode Lenient,
+// les anomalies corrigées. r est lu jusqu'au bout afin de détecter les données qui suivent l'image.
+func DecodePBMWithOptions(r io.Reader, opts ReadOptions) (*PBM, []*FormatError, error) {
+	hr := newHeaderReaderWith(r, opts)
 
-	// Ignorer les commentaires et les lignes vides
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if len(line) > 0 && line[0] != '#' {
-			break
-		}
+	// Lire le numéro magique
+	magicNumber, err := hr.magic("P1 or P4", func(m string) bool { return m == "P1" || m == "P4" })
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Lire la largeur et la hauteur
-	if scanner.Err() != nil {
-		return nil, fmt.Errorf("erreur lors de la lecture de la ligne de dimensions : %v", scanner.Err())
+	pbm, err := decodePBM(hr, magicNumber)
+	if err == nil {
+		err = hr.checkEnd()
 	}
-	dimensions := strings.Fields(scanner.Text())
-	if len(dimensions) != 2 {
-		return nil, fmt.Errorf("ligne de dimensions invalide")
+	if err != nil {
+		return nil, nil, err
 	}
+	return pbm, hr.takeWarnings(), nil
+}
 
-	width, err := strconv.Atoi(dimensions[0])
+// decodePBM lit la suite d'une image PBM dont le numéro magique a déjà été lu.
+func decodePBM(hr *headerReader, magicNumber string) (*PBM, error) {
+	// Lire la largeur et la hauteur (les commentaires sont conservés)
+	width, height, err := hr.dimensions()
 	if err != nil {
-		return nil, fmt.Errorf("échec de l'analyse de la largeur : %v", err)
+		return nil, err
+	}
+	if err := hr.checkSize(width, height, 1, 1); err != nil {
+		return nil, err
 	}
 
-	height, err := strconv.Atoi(dimensions[1])
+	// lire les données
+	pbm := NewPBM(width, height)
+	pbm.MagicNumber = magicNumber
+	pbm.Comments = hr.takeComments()
+	if magicNumber == "P4" {
+		err = readPBMRaw(hr, width, height, func(y int, packed []byte) {
+			row := pbm.Row(y)
+			for x := range row {
+				row[x] = packed[x/8]&(0x80>>uint(x%8)) != 0
+			}
+		})
+	} else {
+		err = readPBMPlain(hr, width, height, func(x, y int) { pbm.Set(x, y, true) })
+	}
 	if err != nil {
-		return nil, fmt.Errorf("échec de l'analyse de la hauteur : %v", err)
+		return nil, err
 	}
+	return pbm, nil
+}
 
-	// lire les données
-	var data [][]bool
-	for scanner.Scan() {
-		line := scanner.Text()
-		tokens := strings.Fields(line)
-		row := make([]bool, width)
-		for i, token := range tokens {
-			if i >= width {
-				break
+// readPBMPlain lit les données ASCII (P1) : width*height chiffres "0" ou "1". Chaque chiffre est
+// un pixel : comme le permet la norme, les blancs et les commentaires qui les séparent sont
+// facultatifs ("0101" désigne quatre pixels). black est appelée pour chaque pixel noir.
+// En mode Lenient, les pixels manquants ou invalides sont blancs.
+func readPBMPlain(hr *headerReader, width, height int, black func(x, y int)) error {
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c, err := hr.readByte()
+			for err == nil && (isSpace(c) || c == '#') {
+				if c == '#' {
+					if err = hr.readComment(); err != nil {
+						break
+					}
+				}
+				c, err = hr.readByte()
+			}
+			if err != nil {
+				fe := hr.readError(err, "pixel data", fmt.Sprintf("%d pixels", width*height), strconv.Itoa(y*width+x))
+				if fe.Err != ErrTruncated || hr.tolerate(fe) != nil {
+					return fe
+				}
+				return nil
 			}
-			if token == "1" {
-				row[i] = true
-			} else if token == "0" {
-				row[i] = false
-			} else {
-				return nil, fmt.Errorf("caractère non valide dans les données : %s", token)
+			switch c {
+			case '1':
+				black(x, y)
+			case '0':
+			default:
+				fe := &FormatError{Offset: hr.offset - 1, Line: int(hr.line), Field: "sample", Expected: `"0" or "1"`, Got: strconv.Quote(string([]byte{c})), Err: ErrBadSample}
+				if err := hr.tolerate(fe); err != nil {
+					return err
+				}
 			}
 		}
-		data = append(data, row)
 	}
+	return nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("erreur lors de la lecture du fichier : %v", err)
+// readPBMRaw lit les données binaires (P4) : 8 pixels par octet, bit de poids fort en premier,
+// chaque ligne étant complétée jusqu'à l'octet suivant. row reçoit chaque ligne lue ; le tampon
+// est réutilisé d'une ligne à l'autre. En mode Lenient, les pixels manquants sont blancs.
+func readPBMRaw(hr *headerReader, width, height int, row func(y int, packed []byte)) error {
+	buf := make([]byte, (width+7)/8)
+	for y := 0; y < height; y++ {
+		start := hr.offset
+		if err := hr.readFull(buf); err != nil {
+			fe := hr.readError(err, "pixel data", fmt.Sprintf("%d rows", height), strconv.Itoa(y))
+			if fe.Err != ErrTruncated || hr.tolerate(fe) != nil {
+				return fe
+			}
+			// complète la ligne commencée puis les suivantes avec des pixels blancs
+			clear(buf[hr.offset-start:])
+			for ; y < height; y++ {
+				row(y, buf)
+				clear(buf)
+			}
+			return nil
+		}
+		row(y, buf)
 	}
-
-	return &PBM{
-		Data:        data,
-		Width:       width,
-		Height:      height,
-		MagicNumber: magicNumber,
-	}, nil
+	return nil
 }
 
 // Size retourne la largeur et la hauteur de l'image
-func (pbm *PBM) Size() (int,int){
-   return pbm.Width, pbm.Height // width = largeur ; height = hauteur (de l'image)
+func (pbm *PBM) Size() (int, int) {
+	return pbm.Width, pbm.Height // width = largeur ; height = hauteur (de l'image)
 
 }
 
-// At retourne la valeur du pixel a (x, y).
-func (pbm *PBM) At(x, y int) bool{
-	 // Vérifie si les coordonnées sont correctes
-	if x < 0 || x >= pbm.Width || y < 0 || y >= pbm.Height {
-		// Coordonnées invalides, retourne une valeur par défaut ou gére l'erreur
-		return false
-	}
+// BitAt retourne la valeur du pixel a (x, y) : true pour un pixel noir, false hors de l'image.
+func (pbm *PBM) BitAt(x, y int) bool {
+	return pbm.Get(x, y)
+}
 
-	// Récupére la valeur du pixel aux coordonnées (x, y)
-	return pbm.Data[y][x]
+// Save enregistre l'image PBM exactement dans le fichier filename, en l'écrasant s'il existe.
+func (pbm *PBM) Save(filename string) error {
+	_, err := pbm.SaveWithOptions(filename, SaveOptions{})
+	return err
 }
 
-func (pbm *PBM) Set(x, y int, value bool) {
-    // Vérifier si les coordonnées sont valides
-    if x >= 0 && x < pbm.Width && y >= 0 && y < pbm.Height {
-        // Modifier la valeur du pixel aux coordonnées (x, y)
-        pbm.Data[y][x] = value
-    }
-    // Si les coordonnées sont invalides, ne rien faire
+// SaveWithOptions enregistre l'image PBM selon opts et renvoie le chemin du fichier réellement écrit.
+func (pbm *PBM) SaveWithOptions(filename string, opts SaveOptions) (string, error) {
+	return saveFile(filename, opts, func() error { return validatePBM(pbm.MagicNumber) }, pbm.Encode)
 }
 
-func (pbm *PBM) Save(filename string) error {
-    // Créer un nom de fichier unique avec la date et l'heure du fichier
-    horodatage := time.Now().Format("2006-01-02-15-04") // exemple de format
-    newFichier := fmt.Sprintf("%s%s", strings.TrimSuffix(filename, ".pbm"), horodatage)
-
-    // Créer ou ouvrir le fichier
-    fichier, err := os.Create(newFichier)
-    if err != nil {
-        return fmt.Errorf("échec de la création du fichier : %v", err)
-    }
-    defer fichier.Close()
-
-    // Écrire le numéro magique
-    _, err = fmt.Fprintf(fichier, "%s\n", pbm.MagicNumber)
-    if err != nil {
-        return fmt.Errorf("échec de l'écriture du numéro magique : %v", err)
-    }
-
-    // Écrire les dimensions
-    _, err = fmt.Fprintf(fichier, "%d %d\n", pbm.Width, pbm.Height)
-    if err != nil {
-        return fmt.Errorf("échec de l'écriture des dimensions : %v", err)
-    }
-
-    // Écrire les données
-	for _, ligne := range pbm.Data {
-		for _, pixel := range ligne {
-			var valeur int
+// Encode écrit l'image PBM dans w.
+func (pbm *PBM) Encode(w io.Writer) error {
+	return encodePBM(w, pbm.MagicNumber, pbm.Comments, pbm.Compact, pbm.Width, pbm.Height, pbm.BitAt, func(y int, packed []byte) {
+		for x, pixel := range pbm.Row(y) {
 			if pixel {
-				valeur = '∎'
-			} else {
-				valeur = '□'
+				packed[x/8] |= 0x80 >> uint(x%8)
 			}
-			_, err := fmt.Fprintf(fichier, "%d ", valeur)
-			if err != nil {
-				return fmt.Errorf("échec de l'écriture de la valeur du pixel : %v", err)
+		}
+	})
+}
+
+// validatePBM vérifie, avant toute écriture, que magicNumber est un numéro magique PBM.
+func validatePBM(magicNumber string) error {
+	if magicNumber != "P1" && magicNumber != "P4" {
+		return fmt.Errorf("unsupported PBM format: %s", magicNumber)
+	}
+	return nil
+}
+
+// encodePBM écrit une image PBM de la taille donnée dans w, précédée des commentaires comments.
+// bitAt donne chaque pixel pour le format ASCII (P1), écrit sans blancs si compact est vrai ;
+// packRow remplit les octets d'une ligne, mis à zéro au préalable, pour le format binaire (P4).
+func encodePBM(w io.Writer, magicNumber string, comments []string, compact bool, width, height int, bitAt func(x, y int) bool, packRow func(y int, packed []byte)) error {
+	writer := bufio.NewWriter(w)
+
+	if err := validatePBM(magicNumber); err != nil {
+		return err
+	}
+
+	// Écrire le numéro magique
+	_, err := fmt.Fprintf(writer, "%s\n", magicNumber)
+	if err != nil {
+		return fmt.Errorf("failed to write magic number: %v", err)
+	}
+	if err := writeComments(writer, comments); err != nil {
+		return err
+	}
+
+	// Écrire les dimensions
+	_, err = fmt.Fprintf(writer, "%d %d\n", width, height)
+	if err != nil {
+		return fmt.Errorf("failed to write dimensions: %v", err)
+	}
+
+	// Écrire les données
+	if magicNumber == "P4" {
+		if err := writePBMRaw(writer, width, height, packRow); err != nil {
+			return err
+		}
+		return writer.Flush()
+	}
+	if err := writePBMPlain(writer, width, height, compact, bitAt); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// writePBMPlain écrit les données ASCII (P1) : "1" pour un pixel noir, "0" pour un blanc,
+// chaque ligne de l'image commençant une nouvelle ligne de texte d'au plus 70 caractères.
+// Les chiffres sont séparés par des espaces, sauf si compact est vrai.
+func writePBMPlain(w io.Writer, width, height int, compact bool, bitAt func(x, y int) bool) error {
+	line := newPlainLine(w)
+	digits := [2][]byte{[]byte("0"), []byte("1")}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			digit := digits[0]
+			if bitAt(x, y) {
+				digit = digits[1]
+			}
+			if err := line.add(digit, !compact); err != nil {
+				return err
 			}
 		}
-		_, err := fmt.Fprintln(fichier) // Nouvelle ligne après chaque ligne de pixels
-		if err != nil {
-			return fmt.Errorf("échec de l'écriture d'un saut de ligne : %v", err)
+		if err := line.end(); err != nil { // Nouvelle ligne après chaque ligne de pixels
+			return err
 		}
 	}
+	return nil
+}
 
+// writePBMRaw écrit les données binaires (P4), 8 pixels par octet.
+func writePBMRaw(w io.Writer, width, height int, packRow func(y int, packed []byte)) error {
+	buf := make([]byte, (width+7)/8)
+	for y := 0; y < height; y++ {
+		for i := range buf {
+			buf[i] = 0
+		}
+		packRow(y, buf)
+		if _, err := w.Write(buf); err != nil {
+			return fmt.Errorf("failed to write pixel data: %v", err)
+		}
+	}
 	return nil
 }
 
-// inverse les couleurs de chaque pixel de l'image pbm
+// Invert inverse les couleurs de chaque pixel de l'image pbm, en mémoire uniquement.
 func (pbm *PBM) Invert() {
-    for y := 0; y < pbm.Height; y++ {
-        for x := 0; x < pbm.Width; x++ {
-            // inverse la valeur de chaque pixel
-            pbm.Data[y][x] = !pbm.Data[y][x]
-        }
-    }
-
-	// Sauvegarde l'image inversée
-	bufio.ErrBufferFull = pbm.Save("image_inverse.pbm")
-	if bufio.ErrBufferFull != nil {
-   		fmt.Println("Erreur lors de la sauvegarde de l'image inversée :", bufio.ErrBufferFull)
-   		return
+	for y := 0; y < pbm.Height; y++ {
+		row := pbm.Row(y)
+		for x := range row {
+			// inverse la valeur de chaque pixel
+			row[x] = !row[x]
+		}
 	}
 }
 
+// FlipAndFlop retourne l'image horizontalement puis verticalement.
 func (pbm *PBM) FlipAndFlop() {
-    // Inverser horizontalement (flip)
-    for y := 0; y < pbm.Height; y++ {
-        for x := 0; x < pbm.Width/2; x++ {
-            // Échanger les pixels symétriques horizontalement
-            pbm.Data[y][x], pbm.Data[y][pbm.Width-x-1] = pbm.Data[y][pbm.Width-x-1], pbm.Data[y][x]
-        }
-    }
-
-    // Inverser verticalement (flop)
-    for y := 0; y < pbm.Height/2; y++ {
-        for x := 0; x < pbm.Width; x++ {
-            // Échanger les lignes symétriques verticalement
-            pbm.Data[y][x], pbm.Data[pbm.Height-y-1][x] = pbm.Data[pbm.Height-y-1][x], pbm.Data[y][x]
-        }
-    }
-}
-
-func (pbm *PBM) SetMagicNumber(magicNumber string) {
-    pbm.MagicNumber = magicNumber
+	pbm.FlipH()
+	pbm.FlipV()
+}
+
+// SetMagicNumber choisit le format d'écriture de l'image : "P1" (ASCII) ou "P4" (binaire).
+func (pbm *PBM) SetMagicNumber(magicNumber string) error {
+	if magicNumber != "P1" && magicNumber != "P4" {
+		return fmt.Errorf("unsupported PBM format: %s", magicNumber)
+	}
+	pbm.MagicNumber = magicNumber
+	return nil
+}
+
+// Channels renvoie le nombre d'échantillons par pixel, toujours 1 (Image).
+func (pbm *PBM) Channels() int {
+	return 1
+}
+
+// MaxValue renvoie la valeur maximale d'un échantillon, toujours 1 (Image).
+func (pbm *PBM) MaxValue() uint {
+	return 1
+}
+
+// Sample renvoie l'échantillon du pixel (x, y) (Image) : comme pour le type de tuple
+// BLACKANDWHITE de PAM, 0 désigne un pixel noir et 1 un pixel blanc.
+func (pbm *PBM) Sample(x, y, c int) uint16 {
+	if c != 0 || !pbm.Contains(x, y) || pbm.Get(x, y) {
+		return 0
+	}
+	return 1
+}
+
+// SetSample définit l'échantillon du pixel (x, y) : 0 pour noir, toute autre valeur pour blanc (Image).
+func (pbm *PBM) SetSample(x, y, c int, value uint16) {
+	if c == 0 {
+		pbm.Set(x, y, value == 0)
+	}
+}
+
+// Clone renvoie une copie indépendante de l'image PBM (Image).
+func (pbm *PBM) Clone() Image {
+	clone := *pbm
+	clone.Raster = pbm.Copy()
+	clone.Comments = append([]string(nil), pbm.Comments...)
+	return &clone
+}
+
+// View renvoie la partie de l'image comprise dans r, qui partage ses pixels avec pbm :
+// modifier l'une modifie l'autre. Les coordonnées de la vue commencent à (0, 0).
+func (pbm *PBM) View(r image.Rectangle) *PBM {
+	view := *pbm
+	view.Raster = pbm.Raster.View(r)
+	return &view
 }
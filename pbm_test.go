@@ -0,0 +1,110 @@
+package netpbm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestPBMRoundTrip lit imageP1.pbm, l'écrit en P1 puis en P4, relit chaque encodage
+// et vérifie que les pixels sont identiques à ceux de l'original.
+func TestPBMRoundTrip(t *testing.T) {
+	original, err := ReadPBM("imageP1.pbm")
+	if err != nil {
+		t.Fatalf("ReadPBM: %v", err)
+	}
+
+	for _, magicNumber := range []string{"P1", "P4"} {
+		if err := original.SetMagicNumber(magicNumber); err != nil {
+			t.Fatalf("SetMagicNumber(%s): %v", magicNumber, err)
+		}
+		var buf bytes.Buffer
+		if err := original.Encode(&buf); err != nil {
+			t.Fatalf("%s: Encode: %v", magicNumber, err)
+		}
+
+		decoded, err := DecodePBM(&buf)
+		if err != nil {
+			t.Fatalf("%s: DecodePBM: %v", magicNumber, err)
+		}
+		if decoded.MagicNumber != magicNumber {
+			t.Fatalf("%s: magic number %s", magicNumber, decoded.MagicNumber)
+		}
+		if decoded.Width != original.Width || decoded.Height != original.Height {
+			t.Fatalf("%s: size %dx%d, want %dx%d", magicNumber, decoded.Width, decoded.Height, original.Width, original.Height)
+		}
+		for y := 0; y < original.Height; y++ {
+			for x := 0; x < original.Width; x++ {
+				if decoded.BitAt(x, y) != original.BitAt(x, y) {
+					t.Fatalf("%s: pixel (%d, %d) = %v, want %v", magicNumber, x, y, decoded.BitAt(x, y), original.BitAt(x, y))
+				}
+			}
+		}
+	}
+}
+
+// TestPBMPlainDigits vérifie que les chiffres d'une image P1 n'ont pas besoin d'être séparés.
+func TestPBMPlainDigits(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  [][]bool
+	}{
+		{"packed digits", "P1 4 1 0101", [][]bool{{false, true, false, true}}},
+		{"split across lines", "P1\n3 2\n01\n10\n1\n0", [][]bool{{false, true, true}, {false, true, false}}},
+		{"comments between digits", "P1 4 1\n0#a\n1# b 0101\n0 1", [][]bool{{false, true, false, true}}},
+	}
+	for _, tt := range tests {
+		pbm, err := DecodePBM(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("%s: DecodePBM: %v", tt.name, err)
+		}
+		for y, row := range tt.want {
+			for x, want := range row {
+				if got := pbm.BitAt(x, y); got != want {
+					t.Fatalf("%s: pixel (%d, %d) = %v, want %v", tt.name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+// TestPBMCompact vérifie que l'écriture compacte respecte la longueur maximale des lignes
+// et se relit à l'identique.
+func TestPBMCompact(t *testing.T) {
+	for _, width := range []int{70, 71} {
+		original := NewPBM(width, 3)
+		original.MagicNumber = "P1"
+		original.Compact = true
+		for y := 0; y < original.Height; y++ {
+			for x := 0; x < width; x++ {
+				original.Set(x, y, (x+y)%3 == 0)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := original.Encode(&buf); err != nil {
+			t.Fatalf("width %d: Encode: %v", width, err)
+		}
+		for i, line := range strings.Split(buf.String(), "\n") {
+			if len(line) > maxPlainLineLength {
+				t.Fatalf("width %d: line %d has %d characters", width, i+1, len(line))
+			}
+			if i >= 2 && strings.ContainsRune(line, ' ') {
+				t.Fatalf("width %d: line %d is not compact: %q", width, i+1, line)
+			}
+		}
+
+		decoded, err := DecodePBM(&buf)
+		if err != nil {
+			t.Fatalf("width %d: DecodePBM: %v", width, err)
+		}
+		for y := 0; y < original.Height; y++ {
+			for x := 0; x < width; x++ {
+				if decoded.BitAt(x, y) != original.BitAt(x, y) {
+					t.Fatalf("width %d: pixel (%d, %d) differs", width, x, y)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package netpbm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// pfmGamma est le gamma utilisé pour passer des échantillons entiers (PGM, PPM) aux valeurs linéaires du PFM.
+const pfmGamma = 2.2
+
+// PFM est une structure pour représenter des images PFM (portable float map).
+// MagicNumber vaut "PF" pour une image couleur et "Pf" pour une image en niveaux de gris.
+// Chaque pixel occupe Channels() valeurs entrelacées, la première ligne étant le haut de l'image.
+type PFM struct {
+	Raster[float32]
+	MagicNumber  string
+	Scale        float32
+	LittleEndian bool
+}
+
+// NewPFM crée une image PFM noire ; magicNumber vaut "PF" (couleur) ou "Pf" (niveaux de gris).
+func NewPFM(width, height int, magicNumber string) *PFM {
+	return &PFM{
+		Raster:       NewRaster[float32](width, height, pfmChannels(magicNumber)),
+		MagicNumber:  magicNumber,
+		Scale:        1,
+		LittleEndian: true,
+	}
+}
+
+// ReadPFM lit une image PFM depuis un fichier et renvoie une structure qui représente l'image.
+// La lecture est stricte : voir ReadOptions et Strict.
+func ReadPFM(filename string) (*PFM, error) {
+	pfm, _, err := ReadPFMWithOptions(filename, ReadOptions{})
+	return pfm, err
+}
+
+// ReadPFMWithOptions lit une image PFM depuis un fichier selon opts et, en mode Lenient, renvoie les
+// anomalies corrigées.
+func ReadPFMWithOptions(filename string, opts ReadOptions) (*PFM, []*FormatError, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	return DecodePFMWithOptions(file, opts)
+}
+
+// DecodePFM lit une image PFM depuis r et renvoie une structure qui représente l'image.
+// La lecture est stricte et consomme r jusqu'au bout ; un Decoder lit les flux de plusieurs images.
+func DecodePFM(r io.Reader) (*PFM, error) {
+	pfm, _, err := DecodePFMWithOptions(r, ReadOptions{})
+	return pfm, err
+}
+
+// DecodePFMWithOptions lit une image PFM depuis r selon opts et, en mode Lenient, renvoie les anomalies
+// corrigées. r est lu jusqu'au bout pour détecter les données en trop.
+func DecodePFMWithOptions(r io.Reader, opts ReadOptions) (*PFM, []*FormatError, error) {
+	hr := newHeaderReaderWith(r, opts)
+
+	// lit le numero magique
+	magicNumber, err := hr.magic("PF or Pf", func(m string) bool { return m == "PF" || m == "Pf" })
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pfm, err := decodePFM(hr, magicNumber)
+	if err == nil {
+		err = hr.checkEnd()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return pfm, hr.takeWarnings(), nil
+}
+
+// decodePFM lit la suite d'une image PFM dont le numéro magique a déjà été lu.
+func decodePFM(hr *headerReader, magicNumber string) (*PFM, error) {
+	// lit la largeur et la hauteur
+	width, height, err := hr.dimensions()
+	if err != nil {
+		return nil, err
+	}
+
+	// lit l'échelle : une valeur négative signale des flottants little-endian
+	token, err := hr.token()
+	if err == nil && token == "" {
+		err = io.EOF
+	}
+	if err != nil {
+		return nil, hr.readError(err, "scale", "non-zero number", "")
+	}
+	scale, err := strconv.ParseFloat(token, 32)
+	if err != nil || scale == 0 {
+		return nil, hr.tokenError(ErrBadHeader, "scale", "non-zero number", strconv.Quote(token))
+	}
+
+	channels := 3
+	if magicNumber == "Pf" {
+		channels = 1
+	}
+	if err := hr.checkSize(width, height, channels, 32); err != nil {
+		return nil, err
+	}
+
+	pfm := NewPFM(width, height, magicNumber)
+	pfm.Scale = float32(math.Abs(scale))
+	pfm.LittleEndian = scale < 0
+
+	// lit les données, de la ligne du bas vers la ligne du haut
+	var order binary.ByteOrder = binary.BigEndian
+	if pfm.LittleEndian {
+		order = binary.LittleEndian
+	}
+	buf := make([]byte, 4*width*pfm.Channels())
+	for y := height - 1; y >= 0; y-- {
+		if err := hr.readFull(buf); err != nil {
+			return nil, hr.readError(err, "pixel data", fmt.Sprintf("%d rows", height), strconv.Itoa(height-1-y))
+		}
+		row := pfm.Row(y)
+		for i := range row {
+			row[i] = math.Float32frombits(order.Uint32(buf[4*i:]))
+		}
+	}
+	return pfm, nil
+}
+
+// Save enregistre l'image PFM exactement dans le fichier filename, en l'écrasant s'il existe.
+func (pfm *PFM) Save(filename string) error {
+	_, err := pfm.SaveWithOptions(filename, SaveOptions{})
+	return err
+}
+
+// SaveWithOptions enregistre l'image PFM selon opts et renvoie le chemin du fichier réellement écrit.
+func (pfm *PFM) SaveWithOptions(filename string, opts SaveOptions) (string, error) {
+	return saveFile(filename, opts, pfm.validate, pfm.Encode)
+}
+
+// validate vérifie, avant toute écriture, que le numéro magique est "PF" ou "Pf" et correspond
+// au nombre de canaux de l'image.
+func (pfm *PFM) validate() error {
+	if pfm.MagicNumber != "PF" && pfm.MagicNumber != "Pf" {
+		return fmt.Errorf("unsupported PFM format: %s", pfm.MagicNumber)
+	}
+	if pfm.Step != pfmChannels(pfm.MagicNumber) {
+		return fmt.Errorf("%s image needs %d channels, has %d", pfm.MagicNumber, pfmChannels(pfm.MagicNumber), pfm.Step)
+	}
+	return nil
+}
+
+// Encode écrit l'image PFM dans w.
+func (pfm *PFM) Encode(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+
+	if err := pfm.validate(); err != nil {
+		return err
+	}
+
+	// ecrit l'en-tête ; le signe de l'échelle indique l'ordre des octets
+	scale := math.Abs(float64(pfm.Scale))
+	if scale == 0 {
+		scale = 1
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if pfm.LittleEndian {
+		scale = -scale
+		order = binary.LittleEndian
+	}
+	if _, err := fmt.Fprintf(writer, "%s\n%d %d\n%s\n", pfm.MagicNumber, pfm.Width, pfm.Height, strconv.FormatFloat(scale, 'f', -1, 64)); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+
+	// ecrit les données, de la ligne du bas vers la ligne du haut
+	buf := make([]byte, 4*pfm.Width*pfm.Step)
+	for y := pfm.Height - 1; y >= 0; y-- {
+		for i, v := range pfm.Row(y) {
+			order.PutUint32(buf[4*i:], math.Float32bits(v))
+		}
+		if _, err := writer.Write(buf); err != nil {
+			return fmt.Errorf("failed to write pixel data: %v", err)
+		}
+	}
+	return writer.Flush()
+}
+
+// pfmChannels renvoie le nombre de valeurs par pixel d'une image PFM de numéro magique donné :
+// 3 pour "PF", 1 pour "Pf".
+func pfmChannels(magicNumber string) int {
+	if magicNumber == "Pf" {
+		return 1
+	}
+	return 3
+}
+
+// Channels renvoie le nombre de valeurs par pixel, c'est-à-dire Step : 3 pour "PF", 1 pour "Pf" (Image).
+func (pfm *PFM) Channels() int {
+	return pfm.Step
+}
+
+// Size renvoie la largeur et la hauteur de l'image.
+func (pfm *PFM) Size() (int, int) {
+	return pfm.Width, pfm.Height
+}
+
+// MaxValue renvoie l'échelle des échantillons entiers exposés par Sample, toujours 65535 (Image).
+func (pfm *PFM) MaxValue() uint {
+	return MaxValue16
+}
+
+// Sample renvoie la valeur du canal c du pixel (x, y), ramenée de [0, 1] à [0, 65535] ;
+// les valeurs hors de cet intervalle sont saturées (Image).
+func (pfm *PFM) Sample(x, y, c int) uint16 {
+	values := pfm.Elems(x, y)
+	if c < 0 || c >= len(values) {
+		return 0
+	}
+	v := float64(values[c])
+	if !(v > 0) {
+		return 0
+	}
+	if v >= 1 {
+		return MaxValue16
+	}
+	return uint16(v*MaxValue16 + 0.5)
+}
+
+// SetSample définit la valeur du canal c du pixel (x, y) à value/65535 (Image).
+func (pfm *PFM) SetSample(x, y, c int, value uint16) {
+	values := pfm.Elems(x, y)
+	if c >= 0 && c < len(values) {
+		values[c] = float32(value) / MaxValue16
+	}
+}
+
+// Clone renvoie une copie indépendante de l'image PFM (Image).
+func (pfm *PFM) Clone() Image {
+	clone := *pfm
+	clone.Raster = pfm.Copy()
+	return &clone
+}
+
+// View renvoie la partie de l'image comprise dans r, qui partage ses valeurs avec pfm :
+// modifier l'une modifie l'autre. Les coordonnées de la vue commencent à (0, 0).
+func (pfm *PFM) View(r image.Rectangle) *PFM {
+	view := *pfm
+	view.Raster = pfm.Raster.View(r)
+	return &view
+}
+
+// ToPPM convertit l'image PFM en PPM 8 bits : les valeurs sont multipliées par exposure,
+// compressées par l'opérateur de Reinhard (v / (1 + v)) puis encodées avec un gamma de 2.2.
+func (pfm *PFM) ToPPM(exposure float64) *PPM {
+	ppm := NewPPM(pfm.Width, pfm.Height, 255)
+	for y := 0; y < pfm.Height; y++ {
+		row := ppm.Row(y)
+		for x := range row {
+			v := pfm.Elems(x, y)
+			if len(v) == 3 {
+				row[x] = Pixel{toneMap(v[0], exposure), toneMap(v[1], exposure), toneMap(v[2], exposure)}
+			} else {
+				g := toneMap(v[0], exposure)
+				row[x] = Pixel{g, g, g}
+			}
+		}
+	}
+	return ppm
+}
+
+// toneMap ramène une valeur HDR linéaire à un échantillon 8 bits ; +Inf donne 255.
+func toneMap(v float32, exposure float64) uint16 {
+	l := float64(v) * exposure
+	if !(l > 0) {
+		return 0 // valeurs négatives, nulles ou NaN
+	}
+	if math.IsInf(l, 1) {
+		return 255
+	}
+	return uint16(math.Min(math.Pow(l/(1+l), 1/pfmGamma)*255+0.5, 255))
+}
+
+// toLinear convertit un échantillon entier en valeur linéaire dans [0, 1].
+func toLinear(value uint16, max uint) float32 {
+	return float32(math.Pow(float64(value)/float64(max), pfmGamma))
+}
+
+// ToPFM convertit l'image PGM en PFM en niveaux de gris ("Pf") à valeurs linéaires dans [0, 1].
+func (pgm *PGM) ToPFM() *PFM {
+	pfm := NewPFM(pgm.Width, pgm.Height, "Pf")
+	for y := 0; y < pgm.Height; y++ {
+		dst := pfm.Row(y)
+		for x, v := range pgm.Row(y) {
+			dst[x] = toLinear(v, pgm.Max)
+		}
+	}
+	return pfm
+}
+
+// ToPFM convertit l'image PPM en PFM couleur ("PF") à valeurs linéaires dans [0, 1].
+func (ppm *PPM) ToPFM() *PFM {
+	pfm := NewPFM(ppm.Width, ppm.Height, "PF")
+	for y := 0; y < ppm.Height; y++ {
+		dst := pfm.Row(y)
+		for x, p := range ppm.Row(y) {
+			dst[3*x] = toLinear(p.R, ppm.Max)
+			dst[3*x+1] = toLinear(p.G, ppm.Max)
+			dst[3*x+2] = toLinear(p.B, ppm.Max)
+		}
+	}
+	return pfm
+}
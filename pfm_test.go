@@ -0,0 +1,47 @@
+package netpbm
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// TestPFMEncodeMagicNumber vérifie qu'Encode refuse un numéro magique inconnu ou qui ne
+// correspond pas au nombre de canaux de l'image.
+func TestPFMEncodeMagicNumber(t *testing.T) {
+	tests := []struct {
+		created, written string
+	}{
+		{"PF", "Pf"},
+		{"Pf", "PF"},
+		{"PF", ""},
+		{"Pf", "P6"},
+	}
+	for _, tt := range tests {
+		pfm := NewPFM(2, 2, tt.created)
+		pfm.MagicNumber = tt.written
+		var buf bytes.Buffer
+		if err := pfm.Encode(&buf); err == nil {
+			t.Fatalf("%s image written as %q: Encode succeeded", tt.created, tt.written)
+		}
+	}
+}
+
+// TestToneMapExtremes vérifie que les valeurs extrêmes donnent du blanc ou du noir.
+func TestToneMapExtremes(t *testing.T) {
+	tests := []struct {
+		v    float32
+		want uint16
+	}{
+		{float32(math.Inf(1)), 255},
+		{math.MaxFloat32, 255},
+		{float32(math.Inf(-1)), 0},
+		{float32(math.NaN()), 0},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		if got := toneMap(tt.v, 1); got != tt.want {
+			t.Fatalf("toneMap(%v) = %d, want %d", tt.v, got, tt.want)
+		}
+	}
+}
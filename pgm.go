@@ -3,246 +3,291 @@ package netpbm
 import (
 	"bufio"
 	"fmt"
+	"image"
+	"io"
 	"os"
-	"strconv"
-	"strings"
-	"time"
 )
 
-// PBM is a structure to represent PBM images.
+// PGM is a structure to represent PGM images.
 type PGM struct {
-	Data          [][]uint8
-	Width, Height int
-	MagicNumber   string
-	Max uint
+	Raster[uint16]
+	MagicNumber string
+	Max         uint
+	// Comments holds the header comments, read by DecodePGM and written by Encode.
+	Comments []string
 }
 
-type Pixel struct{
-	R, G, B uint8
+// NewPGM creates a black raw ("P5") PGM image of the given size and max value.
+func NewPGM(width, height int, max uint) *PGM {
+	return &PGM{Raster: NewRaster[uint16](width, height, 1), MagicNumber: "P5", Max: max}
 }
 
-// ReadPBM reads a PBM image from a file and returns a struct that represents the image.
+// ReadPGM reads a PGM image from a file and returns a struct that represents the image.
+// Reading is strict: see ReadOptions and Strict.
 func ReadPGM(filename string) (*PGM, error) {
+	pgm, _, err := ReadPGMWithOptions(filename, ReadOptions{})
+	return pgm, err
+}
+
+// ReadPGMWithOptions reads a PGM image from a file according to opts and, in Lenient mode,
+// returns the anomalies that were corrected.
+func ReadPGMWithOptions(filename string, opts ReadOptions) (*PGM, []*FormatError, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	return DecodePGMWithOptions(file, opts)
+}
 
-	// lire le nombre magique
-	if !scanner.Scan() {
-		return nil, fmt.Errorf("failed to read magic number")
-	}
-	magicNumber := scanner.Text()
+// DecodePGM reads a PGM image from r and returns a struct that represents the image.
+// Reading is strict and consumes r to the end; use a Decoder for multi-image streams.
+func DecodePGM(r io.Reader) (*PGM, error) {
+	pgm, _, err := DecodePGMWithOptions(r, ReadOptions{})
+	return pgm, err
+}
 
-	if magicNumber != "P1" && magicNumber != "P4" {
-		return nil, fmt.Errorf("unsupported PGM format: %s", magicNumber)
-	}
+// DecodePGMWithOptions reads a PGM image from r according to opts and, in Lenient mode,
+// returns the anomalies that were corrected. r is read to the end to detect trailing data.
+func DecodePGMWithOptions(r io.Reader, opts ReadOptions) (*PGM, []*FormatError, error) {
+	hr := newHeaderReaderWith(r, opts)
 
-	// Skip comments and empty lines
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if len(line) > 0 && line[0] != '#' {
-			break
-		}
+	// lire le nombre magique
+	magicNumber, err := hr.magic("P2 or P5", func(m string) bool { return m == "P2" || m == "P5" })
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Read width and height
-	if scanner.Err() != nil {
-		return nil, fmt.Errorf("error reading dimensions line: %v", scanner.Err())
+	pgm, err := decodePGM(hr, magicNumber)
+	if err == nil {
+		err = hr.checkEnd()
 	}
-	dimensions := strings.Fields(scanner.Text())
-	if len(dimensions) != 2 {
-		return nil, fmt.Errorf("invalid dimensions line")
+	if err != nil {
+		return nil, nil, err
 	}
+	return pgm, hr.takeWarnings(), nil
+}
 
-	width, err := strconv.Atoi(dimensions[0])
+// decodePGM reads the rest of a PGM image whose magic number has already been read.
+func decodePGM(hr *headerReader, magicNumber string) (*PGM, error) {
+	// Read width and height (comments are kept)
+	width, height, err := hr.dimensions()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse width: %v", err)
+		return nil, err
 	}
 
-	height, err := strconv.Atoi(dimensions[1])
+	// Read max value
+	maxValue, err := hr.maxValue()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse height: %v", err)
-	}
-
-	// Read data
-	var data [][]uint8
-	for scanner.Scan() {
-		line := scanner.Text()
-		tokens := strings.Fields(line)
-		row := make([]uint8, width)
-		for i, token := range tokens {
-			if i >= width {
-				break
-			}
-			value, err := strconv.ParseUint(token, 10, 8)
-			if err != nil {
-				return nil, fmt.Errorf("caractère non valide dans les données : %s", token)
-			}
-			row[i] = uint8(value)
-		}
-		data = append(data, row)
+		return nil, err
 	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %v", err)
+	if err := hr.checkSize(width, height, 1, 8*bytesPerSample(maxValue)); err != nil {
+		return nil, err
 	}
 
-	return &PGM{
-		Data:        data,
-		Width:       width,
-		Height:      height,
-		MagicNumber: magicNumber,
-	}, nil
+	// Read data, one row at a time for the raw format
+	pgm := NewPGM(width, height, maxValue)
+	pgm.MagicNumber = magicNumber
+	pgm.Comments = hr.takeComments()
+	if magicNumber == "P5" {
+		buf := make([]byte, width*bytesPerSample(maxValue))
+		for y := 0; y < height && err == nil; y++ {
+			err = readRawSamples(hr, pgm.Row(y), maxValue, buf)
+		}
+	} else {
+		err = readPlainSamples(hr, pgm.Pix, maxValue)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pgm, nil
 }
 
 // Size retourne la largeur et la hauteur de l'image
-func (pgm *PGM) Size() (int,int){
+func (pgm *PGM) Size() (int, int) {
 	return pgm.Width, pgm.Height // width = largeur ; height = hauteur (de l'image)
 
 }
 
-// At retourne la valeur du pixel a (x, y).
-func (pgm *PGM) At(x, y int) uint8{
-	// Vérifie si les coordonnées sont correctes
-   if x < 0 || x >= pgm.Width || y < 0 || y >= pgm.Height {
-	   // Coordonnées invalides, retourne une valeur par défaut ou gére l'erreur
-	   return 0
-   }
+// GrayAt retourne la valeur du pixel a (x, y).
+// Coordonnées invalides : retourne 0.
+func (pgm *PGM) GrayAt(x, y int) uint16 {
+	return pgm.Get(x, y)
+}
 
-   // Récupére la valeur du pixel aux coordonnées (x, y)
-   return pgm.Data[y][x]
+// Save enregistre l'image PGM exactement dans le fichier filename, en l'écrasant s'il existe.
+func (pgm *PGM) Save(filename string) error {
+	_, err := pgm.SaveWithOptions(filename, SaveOptions{})
+	return err
 }
 
-func (pgm *PGM) Set(x, y int, value uint8) {
-    // Vérifier si les coordonnées sont valides
-    if x >= 0 && x < pgm.Width && y >= 0 && y < pgm.Height {
-        // Modifier la valeur du pixel aux coordonnées (x, y)
-        pgm.Data[y][x] = value
-    }
-    // Si les coordonnées sont invalides, ne rien faire
+// SaveWithOptions enregistre l'image PGM selon opts et renvoie le chemin du fichier réellement écrit.
+func (pgm *PGM) SaveWithOptions(filename string, opts SaveOptions) (string, error) {
+	return saveFile(filename, opts, pgm.validate, pgm.Encode)
 }
 
-func (pgm *PGM) Save(filename string) error {
-    // Créer un nom de fichier unique avec un horodatage
-    horodatage := time.Now().Format("2006-01-02-15-04")
-    nouveauNomFichier := fmt.Sprintf("%s%s", strings.TrimSuffix(filename, ".pbm"), horodatage)
-
-    // Créer ou ouvrir le fichier
-    fichier, err := os.Create(nouveauNomFichier)
-    if err != nil {
-        return fmt.Errorf("échec de la création du fichier : %v", err)
-    }
-    defer fichier.Close()
-
-    // Écrire le numéro magique
-    _, err = fmt.Fprintf(fichier, "%s\n", pgm.MagicNumber)
-    if err != nil {
-        return fmt.Errorf("échec de l'écriture du numéro magique : %v", err)
-    }
-
-    // Écrire les dimensions
-    _, err = fmt.Fprintf(fichier, "%d %d\n", pgm.Width, pgm.Height)
-    if err != nil {
-        return fmt.Errorf("échec de l'écriture des dimensions : %v", err)
-    }
-
-    // Écrire les données
-    for _, ligne := range pgm.Data {
-        for _, pixel := range ligne {
-            _, err := fmt.Fprintf(fichier, "%d ", pixel)
-            if err != nil {
-                return fmt.Errorf("échec de l'écriture de la valeur du pixel : %v", err)
-            }
-        }
-        _, err := fmt.Fprintln(fichier) // Nouvelle ligne après chaque ligne de pixels
-        if err != nil {
-            return fmt.Errorf("échec de l'écriture d'un saut de ligne : %v", err)
-        }
-    }
-
-    return nil
-}
-
-// inverse les couleurs de chaque pixel de l'image pbm
-func (pgm *PGM) Invert() {
-    for y := 0; y < pgm.Height; y++ {
-        for x := 0; x < pgm.Width; x++ {
-            // inverse la valeur de chaque pixel
-            pgm.Data[y][x] = uint8(pgm.Max) - pgm.Data[y][x] + 1
-        }
-    }
+// validate checks, before anything is written, that the image can be encoded as it is:
+// known magic number, max value from 1 to 65535 and no sample above it.
+func (pgm *PGM) validate() error {
+	if pgm.MagicNumber != "P2" && pgm.MagicNumber != "P5" {
+		return fmt.Errorf("unsupported PGM format: %s", pgm.MagicNumber)
+	}
+	if pgm.Max < 1 || pgm.Max > MaxValue16 {
+		return fmt.Errorf("unsupported max value: %d", pgm.Max)
+	}
+	for y := 0; y < pgm.Height; y++ {
+		if err := checkSamples(y, pgm.Row(y), pgm.Max); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (pgm *PGM) FlipAndFlop() {
-    // Inverser horizontalement (flip)
-    for y := 0; y < pgm.Height; y++ {
-        for x := 0; x < pgm.Width/2; x++ {
-            // Échanger les pixels symétriques horizontalement
-            pgm.Data[y][x], pgm.Data[y][pgm.Width-x-1] = pgm.Data[y][pgm.Width-x-1], pgm.Data[y][x]
-        }
-    }
+// Encode writes the PGM image to w.
+func (pgm *PGM) Encode(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+
+	if err := pgm.validate(); err != nil {
+		return err
+	}
+
+	// Écrire le numéro magique
+	_, err := fmt.Fprintf(writer, "%s\n", pgm.MagicNumber)
+	if err != nil {
+		return fmt.Errorf("failed to write magic number: %v", err)
+	}
+	if err := writeComments(writer, pgm.Comments); err != nil {
+		return err
+	}
 
-    // Inverser verticalement (flop)
-    for y := 0; y < pgm.Height/2; y++ {
-        for x := 0; x < pgm.Width; x++ {
-            // Échanger les lignes symétriques verticalement
-            pgm.Data[y][x], pgm.Data[pgm.Height-y-1][x] = pgm.Data[pgm.Height-y-1][x], pgm.Data[y][x]
-        }
-    }
+	// Écrire les dimensions
+	_, err = fmt.Fprintf(writer, "%d %d\n", pgm.Width, pgm.Height)
+	if err != nil {
+		return fmt.Errorf("failed to write dimensions: %v", err)
+	}
+
+	// Écrire la valeur maximale
+	_, err = fmt.Fprintf(writer, "%d\n", pgm.Max)
+	if err != nil {
+		return fmt.Errorf("failed to write max value: %v", err)
+	}
+
+	// Écrire les données
+	if pgm.MagicNumber == "P5" {
+		// format binaire : un ou deux octets par pixel selon la valeur maximale
+		for y := 0; y < pgm.Height; y++ {
+			if err := writeRawSamples(writer, pgm.Row(y), pgm.Max); err != nil {
+				return err
+			}
+		}
+		return writer.Flush()
+	}
+	for y := 0; y < pgm.Height; y++ {
+		// chaque ligne de pixels commence une nouvelle ligne de texte
+		if err := writePlainSamples(writer, pgm.Row(y)); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
 }
 
-func (pgm *PGM) SetMagicNumber(magicNumber string) {
-    pgm.MagicNumber = magicNumber
+// Invert inverts the value of every pixel of the PGM image.
+func (pgm *PGM) Invert() {
+	for y := 0; y < pgm.Height; y++ {
+		row := pgm.Row(y)
+		for x := range row {
+			// inverse la valeur de chaque pixel
+			row[x] = uint16(pgm.Max) - row[x]
+		}
+	}
 }
 
-// SetMaxValue sets the max value of the PGM image.
-func (pgm *PGM) SetMaxValue(maxValue uint8) {
-	pgm.Max = uint(maxValue)
+// FlipAndFlop flips the PGM image horizontally then vertically.
+func (pgm *PGM) FlipAndFlop() {
+	pgm.FlipH()
+	pgm.FlipV()
 }
 
-// Rotate90CW rotates the PGM image 90° clockwise.
-func (pgm *PGM) Rotate90CW() {
-	// Transpose the matrix
-	for i := 0; i < pgm.Height; i++ {
-		for j := i + 1; j < pgm.Width; j++ {
-			pgm.Data[i][j], pgm.Data[j][i] = pgm.Data[j][i], pgm.Data[i][j]
-		}
+// SetMagicNumber selects the output format: "P2" (plain) or "P5" (raw).
+func (pgm *PGM) SetMagicNumber(magicNumber string) error {
+	if magicNumber != "P2" && magicNumber != "P5" {
+		return fmt.Errorf("unsupported PGM format: %s", magicNumber)
 	}
+	pgm.MagicNumber = magicNumber
+	return nil
+}
 
-	// Reverse each row
-	for i := 0; i < pgm.Height; i++ {
-		for j, k := 0, pgm.Width-1; j < k; j, k = j+1, k-1 {
-			pgm.Data[i][j], pgm.Data[i][k] = pgm.Data[i][k], pgm.Data[i][j]
+// SetMaxValue sets the max value of the PGM image and rescales every sample to it.
+func (pgm *PGM) SetMaxValue(maxValue uint16) error {
+	if maxValue == 0 {
+		return fmt.Errorf("unsupported max value: %d", maxValue)
+	}
+	for y := 0; y < pgm.Height; y++ {
+		row := pgm.Row(y)
+		for x := range row {
+			row[x] = scaleSample(row[x], pgm.Max, uint(maxValue))
 		}
 	}
+	pgm.Max = uint(maxValue)
+	return nil
 }
 
 // ToPBM converts the PGM image to PBM.
 func (pgm *PGM) ToPBM() *PBM {
-    // Créer une nouvelle structure PBM
-    pbm := &PBM{
-        Width:       pgm.Width,
-        Height:      pgm.Height,
-        MagicNumber: "P1", // PBM a le numéro magique "P1"
-    }
-
-    // Initialiser les données de l'image PBM
-    pbm.Data = make([][]uint8, pgm.Height)
-    for y := 0; y < pgm.Height; y++ {
-        pbm.Data[y] = make([]uint8, pgm.Width)
-        for x := 0; x < pgm.Width; x++ {
-            // Définir un seuil pour convertir en 1 ou 0
-            if pgm.Data[y][x] > pgm.Max/2 {
-                pbm.Data[y][x] = 1
-            } else {
-                pbm.Data[y][x] = 0
-            }
-        }
-    }
-
-    return pbm
-}
\ No newline at end of file
+	// Créer une nouvelle structure PBM
+	pbm := NewPBM(pgm.Width, pgm.Height)
+	pbm.MagicNumber = "P1" // PBM a le numéro magique "P1"
+
+	for y := 0; y < pgm.Height; y++ {
+		dst := pbm.Row(y)
+		for x, v := range pgm.Row(y) {
+			// Définir un seuil : les pixels sombres deviennent noirs (1)
+			dst[x] = 2*uint(v) < pgm.Max
+		}
+	}
+
+	return pbm
+}
+
+// Channels returns the number of samples per pixel, always 1 (Image).
+func (pgm *PGM) Channels() int {
+	return 1
+}
+
+// MaxValue returns the maximum sample value (Image).
+func (pgm *PGM) MaxValue() uint {
+	return pgm.Max
+}
+
+// Sample returns the gray value of pixel (x, y); c must be 0 (Image).
+func (pgm *PGM) Sample(x, y, c int) uint16 {
+	if c != 0 {
+		return 0
+	}
+	return pgm.GrayAt(x, y)
+}
+
+// SetSample sets the gray value of pixel (x, y); c must be 0 (Image).
+func (pgm *PGM) SetSample(x, y, c int, value uint16) {
+	if c == 0 {
+		pgm.Set(x, y, value)
+	}
+}
+
+// Clone returns an independent copy of the PGM image (Image).
+func (pgm *PGM) Clone() Image {
+	clone := *pgm
+	clone.Raster = pgm.Copy()
+	clone.Comments = append([]string(nil), pgm.Comments...)
+	return &clone
+}
+
+// View returns the part of the image inside r, sharing its pixels with pgm:
+// changing one changes the other. The view's coordinates start at (0, 0).
+func (pgm *PGM) View(r image.Rectangle) *PGM {
+	view := *pgm
+	view.Raster = pgm.Raster.View(r)
+	return &view
+}
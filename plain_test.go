@@ -0,0 +1,67 @@
+package netpbm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestPlainLineLength vérifie que les écrivains ASCII P2 et P3 coupent les lignes à 70 caractères
+// et que le résultat se relit à l'identique.
+func TestPlainLineLength(t *testing.T) {
+	pgm := NewPGM(40, 2, 65535)
+	pgm.MagicNumber = "P2"
+	ppm := NewPPM(40, 2, 65535)
+	ppm.MagicNumber = "P3"
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 40; x++ {
+			pgm.Set(x, y, uint16(1000*x+y))
+			ppm.Set(x, y, Pixel{uint16(1000 * x), uint16(y), 65535})
+		}
+	}
+
+	for _, img := range []Image{pgm, ppm} {
+		var buf bytes.Buffer
+		if err := img.Encode(&buf); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		for i, line := range strings.Split(buf.String(), "\n") {
+			if len(line) > maxPlainLineLength {
+				t.Fatalf("%T: line %d has %d characters", img, i+1, len(line))
+			}
+		}
+		decoded, _, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("%T: Decode: %v", img, err)
+		}
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 40; x++ {
+				for c := 0; c < img.Channels(); c++ {
+					if got, want := decoded.Sample(x, y, c), img.Sample(x, y, c); got != want {
+						t.Fatalf("%T: sample (%d, %d, %d) = %d, want %d", img, x, y, c, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestEncodeAboveMax vérifie que les écrivains refusent les échantillons supérieurs à la valeur
+// maximale au lieu de les tronquer.
+func TestEncodeAboveMax(t *testing.T) {
+	pgm := NewPGM(1, 1, 255)
+	pgm.Set(0, 0, 300)
+	ppm := NewPPM(1, 1, 255)
+	ppm.Set(0, 0, Pixel{0, 300, 0})
+	pam := NewPAM(1, 1, 2, 255, "")
+	pam.Set(0, 0, []uint16{0, 300})
+	for _, img := range []Image{pgm, ppm, pam} {
+		var buf bytes.Buffer
+		if err := img.Encode(&buf); err == nil {
+			t.Fatalf("%T: Encode accepted a sample above max value", img)
+		}
+		if buf.Len() != 0 {
+			t.Fatalf("%T: Encode wrote %d bytes before failing", img, buf.Len())
+		}
+	}
+}
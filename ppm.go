@@ -3,29 +3,32 @@ package netpbm
 import (
 	"bufio"
 	"fmt"
+	"image"
+	"io"
 	"math"
 	"math/rand"
 	"os"
-	"strconv"
-	"strings"
-	"image"
-	"image/color"
-	"image/draw"
-	"image/png"
 	"sort"
 )
 
 // PPM is a structure to represent PPM images.
 type PPM struct {
-	Data        [][]Pixel
-	Width, Height int
+	Raster[Pixel]
 	MagicNumber string
 	Max         uint
+	// Comments contient les commentaires de l'en-tête, lus par DecodePPM et écrits par Encode.
+	Comments []string
+}
+
+// NewPPM crée une image PPM noire au format binaire "P6" de la taille et de la valeur maximale données.
+func NewPPM(width, height int, max uint) *PPM {
+	return &PPM{Raster: NewRaster[Pixel](width, height, 1), MagicNumber: "P6", Max: max}
 }
 
 // Pixel represents a pixel with red (R), green (G), and blue (B) channels.
+// Les échantillons vont jusqu'à 65535 pour les images 16 bits.
 type Pixel struct {
-	R, G, B uint8
+	R, G, B uint16
 }
 
 // Point represents a point in the image.
@@ -34,96 +37,94 @@ type Point struct {
 }
 
 // ReadPPM lit une image PPM depuis un fichier et renvoie une structure qui représente l'image.
+// Elle est stricte : voir ReadOptions et Strict.
 func ReadPPM(filename string) (*PPM, error) {
+	ppm, _, err := ReadPPMWithOptions(filename, ReadOptions{})
+	return ppm, err
+}
+
+// ReadPPMWithOptions lit une image PPM à partir d'un fichier selon opts et renvoie, en mode
+// Lenient, les anomalies corrigées.
+func ReadPPMWithOptions(filename string, opts ReadOptions) (*PPM, []*FormatError, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	return DecodePPMWithOptions(file, opts)
+}
 
-	// lit le numero magique
-	if !scanner.Scan() {
-		return nil, fmt.Errorf("failed to read magic number")
-	}
-	magicNumber := scanner.Text()
+// DecodePPM lit une image PPM depuis r et renvoie une structure qui représente l'image.
+// Elle est stricte et lit r jusqu'au bout ; pour un flux de plusieurs images, utiliser Decoder.
+func DecodePPM(r io.Reader) (*PPM, error) {
+	ppm, _, err := DecodePPMWithOptions(r, ReadOptions{})
+	return ppm, err
+}
 
-	if magicNumber != "P3" && magicNumber != "P6" {
-		return nil, fmt.Errorf("unsupported PPM format: %s", magicNumber)
-	}
+// DecodePPMWithOptions lit une image PPM depuis r selon opts et renvoie, en mode Lenient,
+// les anomalies corrigées. r est lu jusqu'au bout afin de détecter les données qui suivent l'image.
+func DecodePPMWithOptions(r io.Reader, opts ReadOptions) (*PPM, []*FormatError, error) {
+	hr := newHeaderReaderWith(r, opts)
 
-	// passe les commentaires et les lignes vides
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if len(line) > 0 && line[0] != '#' {
-			break
-		}
+	// lit le numero magique
+	magicNumber, err := hr.magic("P3 or P6", func(m string) bool { return m == "P3" || m == "P6" })
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// lit la largeur et la hauteur
-	if scanner.Err() != nil {
-		return nil, fmt.Errorf("error reading dimensions line: %v", scanner.Err())
-	}
-	dimensions := strings.Fields(scanner.Text())
-	if len(dimensions) != 2 {
-		return nil, fmt.Errorf("invalid dimensions line")
+	ppm, err := decodePPM(hr, magicNumber)
+	if err == nil {
+		err = hr.checkEnd()
 	}
-
-	width, err := strconv.Atoi(dimensions[0])
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse width: %v", err)
+		return nil, nil, err
 	}
+	return ppm, hr.takeWarnings(), nil
+}
 
-	height, err := strconv.Atoi(dimensions[1])
+// decodePPM lit la suite d'une image PPM dont le numéro magique a déjà été lu.
+func decodePPM(hr *headerReader, magicNumber string) (*PPM, error) {
+	// lit la largeur et la hauteur (les commentaires sont conservés)
+	width, height, err := hr.dimensions()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse height: %v", err)
+		return nil, err
 	}
 
 	// lit la valeur max
-	if !scanner.Scan() {
-		return nil, fmt.Errorf("failed to read max value")
-	}
-	maxValue, err := strconv.Atoi(scanner.Text())
+	maxValue, err := hr.maxValue()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse max value: %v", err)
+		return nil, err
+	}
+	if err := hr.checkSize(width, height, 3, 8*bytesPerSample(maxValue)); err != nil {
+		return nil, err
 	}
 
-	// lit les données
-	var data [][]Pixel
+	// lit les données ligne par ligne, directement dans l'image
+	ppm := NewPPM(width, height, maxValue)
+	ppm.MagicNumber = magicNumber
+	ppm.Comments = hr.takeComments()
+	samples := make([]uint16, 3*width)
+	var buf []byte
+	if magicNumber == "P6" {
+		// format binaire, 2 octets par échantillon au-delà de 255
+		buf = make([]byte, len(samples)*bytesPerSample(maxValue))
+	}
 	for y := 0; y < height; y++ {
-		var row []Pixel
-		for x := 0; x < width; x++ {
-			var pixel Pixel
-			if magicNumber == "P3" {
-				// format ASCII
-				if _, err := fmt.Fscanf(Scanner, "%d %d %d", &pixel.R, &pixel.G, &pixel.B); err != nil {
-					return nil, fmt.Errorf("failed to parse pixel data: %v", err)
-				}
-			} else {
-				// Format binaire (P6)
-				var buf [3]byte
-				if _, err := file.Read(buf[:]); err != nil {
-					return nil, fmt.Errorf("failed to read pixel data: %v", err)
-				}
-				pixel.R, pixel.G, pixel.B = buf[0], buf[1], buf[2]
-			}
-			row = append(row, pixel)
+		if buf != nil {
+			err = readRawSamples(hr, samples, maxValue, buf)
+		} else {
+			err = readPlainSamples(hr, samples, maxValue)
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := ppm.Row(y)
+		for x := range row {
+			row[x] = Pixel{samples[3*x], samples[3*x+1], samples[3*x+2]}
 		}
-		data = append(data, row)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %v", err)
 	}
-
-	return &PPM{
-		Data:        data,
-		Width:       width,
-		Height:      height,
-		MagicNumber: magicNumber,
-		Max:         uint(maxValue),
-	}, nil
+	return ppm, nil
 }
 
 // Size renvoie la largeur et la hauteur de l'image.
@@ -131,144 +132,206 @@ func (ppm *PPM) Size() (int, int) {
 	return ppm.Width, ppm.Height
 }
 
-// At renvoie la valeur du pixel à la position (x, y).
-func (ppm *PPM) At(x, y int) Pixel {
-	return ppm.Data[y][x]
+// PixelAt renvoie la valeur du pixel à la position (x, y), ou un pixel noir si les coordonnées sont invalides.
+func (ppm *PPM) PixelAt(x, y int) Pixel {
+	return ppm.Get(x, y)
+}
+
+// Save enregistre l'image PPM exactement dans le fichier filename, en l'écrasant s'il existe.
+func (ppm *PPM) Save(filename string) error {
+	_, err := ppm.SaveWithOptions(filename, SaveOptions{})
+	return err
+}
+
+// SaveWithOptions enregistre l'image PPM selon opts et renvoie le chemin du fichier réellement écrit.
+func (ppm *PPM) SaveWithOptions(filename string, opts SaveOptions) (string, error) {
+	return saveFile(filename, opts, ppm.validate, ppm.Encode)
 }
 
-// Set définit la valeur du pixel à la position (x, y).
-func (ppm *PPM) Set(x, y int, value Pixel) {
-	if x >= 0 && x < ppm.Width && y >= 0 && y < ppm.Height {
-		ppm.Data[y][x] = value
+// validate vérifie, avant toute écriture, que l'image peut être écrite telle quelle : numéro
+// magique connu, valeur maximale de 1 à 65535 et aucun échantillon au-delà.
+func (ppm *PPM) validate() error {
+	if ppm.MagicNumber != "P3" && ppm.MagicNumber != "P6" {
+		return fmt.Errorf("unsupported PPM format: %s", ppm.MagicNumber)
+	}
+	if ppm.Max < 1 || ppm.Max > MaxValue16 {
+		return fmt.Errorf("unsupported max value: %d", ppm.Max)
+	}
+	for y := 0; y < ppm.Height; y++ {
+		for x, p := range ppm.Row(y) {
+			if uint(p.R) > ppm.Max || uint(p.G) > ppm.Max || uint(p.B) > ppm.Max {
+				return fmt.Errorf("pixel %d of row %d is %v, above max value %d", x, y, p, ppm.Max)
+			}
+		}
 	}
+	return nil
 }
 
-// Save enregistre l'image PPM dans un fichier et renvoie une erreur s'il y a un problème.
-func (ppm *PPM) Save(filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %v", err)
+// Encode écrit l'image PPM dans w.
+func (ppm *PPM) Encode(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+
+	if err := ppm.validate(); err != nil {
+		return err
 	}
-	defer file.Close()
 
 	// ecrit le nombre magique
-	if _, err := fmt.Fprintf(file, "%s\n", ppm.MagicNumber); err != nil {
+	if _, err := fmt.Fprintf(writer, "%s\n", ppm.MagicNumber); err != nil {
 		return fmt.Errorf("failed to write magic number: %v", err)
 	}
+	if err := writeComments(writer, ppm.Comments); err != nil {
+		return err
+	}
 
 	// ecrit la largeur et la hauteur
-	if _, err := fmt.Fprintf(file, "%d %d\n", ppm.Width, ppm.Height); err != nil {
+	if _, err := fmt.Fprintf(writer, "%d %d\n", ppm.Width, ppm.Height); err != nil {
 		return fmt.Errorf("failed to write dimensions: %v", err)
 	}
 
 	// ecrit la valeur max
-	if _, err := fmt.Fprintf(file, "%d\n", ppm.Max); err != nil {
+	if _, err := fmt.Fprintf(writer, "%d\n", ppm.Max); err != nil {
 		return fmt.Errorf("failed to write max value: %v", err)
 	}
 
 	// ecrit les données
+	row := make([]uint16, 3*ppm.Width)
 	for y := 0; y < ppm.Height; y++ {
-		for x := 0; x < ppm.Width; x++ {
-			if ppm.MagicNumber == "P3" {
-				// format ASCII
-				if _, err := fmt.Fprintf(file, "%d %d %d ", ppm.Data[y][x].R, ppm.Data[y][x].G, ppm.Data[y][x].B); err != nil {
-					return fmt.Errorf("failed to write pixel data: %v", err)
-				}
-			} else {
-				// format binaire (P6)
-				if _, err := file.Write([]byte{ppm.Data[y][x].R, ppm.Data[y][x].G, ppm.Data[y][x].B}); err != nil {
-					return fmt.Errorf("failed to write pixel data: %v", err)
-				}
-			}
+		for x, p := range ppm.Row(y) {
+			row[3*x], row[3*x+1], row[3*x+2] = p.R, p.G, p.B
 		}
+		var err error
 		if ppm.MagicNumber == "P3" {
-			// ajoute une nouvelle ligne après chaque ligne au format ASCII
-			if _, err := fmt.Fprint(file, "\n"); err != nil {
-				return fmt.Errorf("failed to write newline: %v", err)
-			}
+			// format ASCII, chaque ligne de pixels commençant une nouvelle ligne de texte
+			err = writePlainSamples(writer, row)
+		} else {
+			// format binaire (P6), 2 octets big-endian par échantillon au-delà de 255
+			err = writeRawSamples(writer, row, ppm.Max)
+		}
+		if err != nil {
+			return err
 		}
 	}
 
-	return nil
+	return writer.Flush()
+}
+
+// Channels renvoie le nombre d'échantillons par pixel, toujours 3 (Image).
+func (ppm *PPM) Channels() int {
+	return 3
+}
+
+// MaxValue renvoie la valeur maximale d'un échantillon (Image).
+func (ppm *PPM) MaxValue() uint {
+	return ppm.Max
+}
+
+// Sample renvoie le canal c (0 rouge, 1 vert, 2 bleu) du pixel (x, y) (Image).
+func (ppm *PPM) Sample(x, y, c int) uint16 {
+	p := ppm.PixelAt(x, y)
+	switch c {
+	case 0:
+		return p.R
+	case 1:
+		return p.G
+	case 2:
+		return p.B
+	}
+	return 0
+}
+
+// SetSample définit le canal c (0 rouge, 1 vert, 2 bleu) du pixel (x, y) (Image).
+func (ppm *PPM) SetSample(x, y, c int, value uint16) {
+	i := ppm.Offset(x, y)
+	if i < 0 {
+		return
+	}
+	p := &ppm.Pix[i]
+	switch c {
+	case 0:
+		p.R = value
+	case 1:
+		p.G = value
+	case 2:
+		p.B = value
+	}
+}
+
+// Clone renvoie une copie indépendante de l'image PPM (Image).
+func (ppm *PPM) Clone() Image {
+	clone := *ppm
+	clone.Raster = ppm.Copy()
+	clone.Comments = append([]string(nil), ppm.Comments...)
+	return &clone
+}
+
+// View renvoie la partie de l'image comprise dans r, qui partage ses pixels avec ppm :
+// modifier l'une modifie l'autre. Les coordonnées de la vue commencent à (0, 0).
+func (ppm *PPM) View(r image.Rectangle) *PPM {
+	view := *ppm
+	view.Raster = ppm.Raster.View(r)
+	return &view
 }
 
 // Invert inverse les couleurs de l'image PPM.
 func (ppm *PPM) Invert() {
 	for y := 0; y < ppm.Height; y++ {
-		for x := 0; x < ppm.Width; x++ {
-			ppm.Data[y][x].R = ppm.Max - ppm.Data[y][x].R
-			ppm.Data[y][x].G = ppm.Max - ppm.Data[y][x].G
-			ppm.Data[y][x].B = ppm
-
+		row := ppm.Row(y)
+		for x := range row {
+			p := &row[x]
+			p.R = uint16(ppm.Max) - p.R
+			p.G = uint16(ppm.Max) - p.G
+			p.B = uint16(ppm.Max) - p.B
 		}
 	}
 }
+
 // Flip retourne l'image PPM horizontalement.
 func (ppm *PPM) Flip() {
-	for y := 0; y < ppm.Height; y++ {
-		for x := 0; x < ppm.Width/2; x++ {
-			ppm.Data[y][x], ppm.Data[y][ppm.Width-x-1] = ppm.Data[y][ppm.Width-x-1], ppm.Data[y][x]
-		}
-	}
+	ppm.FlipH()
 }
 
 // Flop fait basculer l'image PPM verticalement.
 func (ppm *PPM) Flop() {
-	for y := 0; y < ppm.Height/2; y++ {
-		ppm.Data[y], ppm.Data[ppm.Height-y-1] = ppm.Data[ppm.Height-y-1], ppm.Data[y]
-	}
+	ppm.FlipV()
 }
 
-// SetMagicNumber définit le nombre magique de l'image PPM.
-func (ppm *PPM) SetMagicNumber(magicNumber string) {
+// SetMagicNumber choisit le format d'écriture de l'image PPM : "P3" (ASCII) ou "P6" (binaire).
+func (ppm *PPM) SetMagicNumber(magicNumber string) error {
+	if magicNumber != "P3" && magicNumber != "P6" {
+		return fmt.Errorf("unsupported PPM format: %s", magicNumber)
+	}
 	ppm.MagicNumber = magicNumber
+	return nil
 }
 
-// SetMaxValue définit la valeur maximale de l'image PPM.
-func (ppm *PPM) SetMaxValue(maxValue uint8) {
-	ppm.Max = uint(maxValue)
-}
-
-// Rotate90CW fait pivoter l’image PPM de 90° dans le sens des aiguilles d’une montre.
-func (ppm *PPM) Rotate90CW() {
-	// Créez une nouvelle image RGBA et dessinez dessus les pixels pivotés
-	newImage := image.NewRGBA(image.Rect(0, 0, ppm.Height, ppm.Width))
-
-	for y := 0; y < ppm.Height; y++ {
-		for x := 0; x < ppm.Width; x++ {
-			newImage.Set(y, ppm.Width-x-1, color.RGBA{ppm.Data[y][x].R, ppm.Data[y][x].G, ppm.Data[y][x].B, 255})
-		}
+// SetMaxValue définit la valeur maximale de l'image PPM et remet les échantillons à l'échelle.
+func (ppm *PPM) SetMaxValue(maxValue uint16) error {
+	if maxValue == 0 {
+		return fmt.Errorf("unsupported max value: %d", maxValue)
 	}
-
-	// Mettre à jour les propriétés PPM avec les données pivotées
-	ppm.Width, ppm.Height = ppm.Height, ppm.Width
-	ppm.Data = make([][]Pixel, ppm.Height)
 	for y := 0; y < ppm.Height; y++ {
-		ppm.Data[y] = make([]Pixel, ppm.Width)
-		for x := 0; x < ppm.Width; x++ {
-			r, g, b, _ := newImage.At(y, x).RGBA()
-			ppm.Data[y][x] = Pixel{uint8(r), uint8(g), uint8(b)}
+		row := ppm.Row(y)
+		for x := range row {
+			p := &row[x]
+			p.R = scaleSample(p.R, ppm.Max, uint(maxValue))
+			p.G = scaleSample(p.G, ppm.Max, uint(maxValue))
+			p.B = scaleSample(p.B, ppm.Max, uint(maxValue))
 		}
 	}
+	ppm.Max = uint(maxValue)
+	return nil
 }
 
 // ToPGM convertit l'image PPM en PGM.
 func (ppm *PPM) ToPGM() *PGM {
 	// Créez une nouvelle image PGM avec les mêmes dimensions
-	pgm := &PGM{
-		Data:        make([][]uint8, ppm.Height),
-		Width:       ppm.Width,
-		Height:      ppm.Height,
-		MagicNumber: "P5",
-		Max:         ppm.Max,
-	}
+	pgm := NewPGM(ppm.Width, ppm.Height, ppm.Max)
 
 	for y := 0; y < ppm.Height; y++ {
-		pgm.Data[y] = make([]uint8, ppm.Width)
-		for x := 0; x < ppm.Width; x++ {
+		dst := pgm.Row(y)
+		for x, p := range ppm.Row(y) {
 			// Convertir RVB en niveaux de gris en utilisant la méthode de luminosité
-			gray := uint8(0.299*float64(ppm.Data[y][x].R) + 0.587*float64(ppm.Data[y][x].G) + 0.114*float64(ppm.Data[y][x].B))
-			pgm.Data[y][x] = gray
+			dst[x] = uint16(0.299*float64(p.R) + 0.587*float64(p.G) + 0.114*float64(p.B) + 0.5)
 		}
 	}
 
@@ -278,23 +341,15 @@ func (ppm *PPM) ToPGM() *PGM {
 // ToPBM convertit l'image PPM en PBM.
 func (ppm *PPM) ToPBM() *PBM {
 	// Créer une nouvelle image PBM avec les mêmes dimensions
-	pbm := &PBM{
-		Data:        make([][]uint8, ppm.Height),
-		Width:       ppm.Width,
-		Height:      ppm.Height,
-		MagicNumber: "P1",
-	}
+	pbm := NewPBM(ppm.Width, ppm.Height)
+	pbm.MagicNumber = "P1"
 
 	for y := 0; y < ppm.Height; y++ {
-		pbm.Data[y] = make([]uint8, ppm.Width)
-		for x := 0; x < ppm.Width; x++ {
-			// Convertir RVB en binaire en utilisant un seuil
-			threshold := uint8((uint(ppm.Data[y][x].R) + uint(ppm.Data[y][x].G) + uint(ppm.Data[y][x].B)) / 3)
-			if threshold > ppm.Max/2 {
-				pbm.Data[y][x] = 1
-			} else {
-				pbm.Data[y][x] = 0
-			}
+		dst := pbm.Row(y)
+		for x, p := range ppm.Row(y) {
+			// Convertir RVB en binaire en utilisant un seuil : les pixels sombres deviennent noirs (1)
+			threshold := (uint(p.R) + uint(p.G) + uint(p.B)) / 3
+			dst[x] = 2*threshold < ppm.Max
 		}
 	}
 
@@ -352,7 +407,7 @@ func (ppm *PPM) DrawLine(p1, p2 Point, color Pixel) {
 	}
 }
 
-//DrawRectangle dessine un rectangle.
+// DrawRectangle dessine un rectangle.
 func (ppm *PPM) DrawRectangle(p1 Point, width, height int, color Pixel) {
 	p2 := Point{p1.X + width, p1.Y}
 	p3 := Point{p1.X + width, p1.Y + height}
@@ -460,15 +515,9 @@ func (ppm *PPM) DrawFilledTriangle(p1, p2, p3 Point, color Pixel) {
 // DrawPolygon dessine un polygone.
 func (ppm *PPM) DrawPolygon(points []Point, color Pixel) {
 	numPoints := len(points)
-	for i := 0; i < numPoints-1; i++ {
-		ppm.DrawLine(points[i], points[i+1], color)
+	if numPoints == 0 {
+		return
 	}
-	ppm.DrawLine(points[numPoints-1], points[0], color)
-}
-
-// DrawPolygon dessine un polygone.
-func (ppm *PPM) DrawPolygon(points []Point, color Pixel) {
-	numPoints := len(points)
 	for i := 0; i < numPoints-1; i++ {
 		ppm.DrawLine(points[i], points[i+1], color)
 	}
@@ -478,6 +527,9 @@ func (ppm *PPM) DrawPolygon(points []Point, color Pixel) {
 // DrawFilledPolygon dessine un polygone rempli.
 func (ppm *PPM) DrawFilledPolygon(points []Point, color Pixel) {
 	// Utilise la balayage de lignes pour remplir le polygone
+	if len(points) == 0 {
+		return
+	}
 	minY, maxY := points[0].Y, points[0].Y
 	for _, p := range points {
 		if p.Y < minY {
@@ -507,7 +559,7 @@ func (ppm *PPM) DrawFilledPolygon(points []Point, color Pixel) {
 		sort.Ints(intersections)
 
 		// Remplit les pixels entre les intersections
-		for i := 0; i < len(intersections); i += 2 {
+		for i := 0; i+1 < len(intersections); i += 2 {
 			x1 := intersections[i]
 			x2 := intersections[i+1]
 
@@ -528,12 +580,13 @@ func (ppm *PPM) DrawKochSnowflake(n int, start Point, width int, color Pixel) {
 	height := int(float64(width) * math.Sqrt(3.0) / 2.0)
 	p1 := start
 	p2 := Point{start.X + width, start.Y}
-	p3 := Point{start.X + width / 2, start.Y - height}
+	p3 := Point{start.X + width/2, start.Y - height}
 
 	// Calcule les points des segments du flocon de Koch
 	p4 := Point{(2*p1.X + p3.X) / 3, (2*p1.Y + p3.Y) / 3}
 	p5 := Point{(p1.X + 2*p3.X) / 3, (p1.Y + 2*p3.Y) / 3}
-	p6 := Point{p4.X + (p5.X-p4.X)*0.5 - (p5.Y-p4.Y)*math.Sqrt(3.0)/2.0, p4.Y + (p5.X-p4.X)*math.Sqrt(3.0)/2.0 + (p5.Y-p4.Y)*0.5}
+	dx, dy := float64(p5.X-p4.X), float64(p5.Y-p4.Y)
+	p6 := Point{p4.X + int(dx*0.5-dy*math.Sqrt(3.0)/2.0), p4.Y + int(dx*math.Sqrt(3.0)/2.0+dy*0.5)}
 
 	// Dessine les segments du flocon de Koch
 	ppm.DrawLine(p1, p2, color)
@@ -560,7 +613,7 @@ func (ppm *PPM) DrawSierpinskiTriangle(n int, start Point, width int, color Pixe
 	height := int(float64(width) * math.Sqrt(3.0) / 2.0)
 	p1 := start
 	p2 := Point{start.X + width, start.Y}
-	p3 := Point{start.X + width / 2, start.Y - height}
+	p3 := Point{start.X + width/2, start.Y - height}
 
 	// Calcule le milieu des côtés
 	mid1 := Point{(p1.X + p2.X) / 2, (p1.Y + p2.Y) / 2}
@@ -583,22 +636,32 @@ func (ppm *PPM) DrawSierpinskiTriangle(n int, start Point, width int, color Pixe
 
 // DrawPerlinNoise dessine du bruit de Perlin.
 func (ppm *PPM) DrawPerlinNoise(color1 Pixel, color2 Pixel) {
-	// Génère une grille de vecteurs aléatoires
-	grid := make([][]Point, ppm.Height)
-	for y := 0; y < ppm.Height; y++ {
-		grid[y] = make([]Point, ppm.Width)
-		for x := 0; x < ppm.Width; x++ {
+	// Génère une grille de vecteurs aléatoires, avec une ligne et une colonne
+	// supplémentaires pour interpoler les pixels du bord
+	grid := make([][]Point, ppm.Height+1)
+	for y := 0; y <= ppm.Height; y++ {
+		grid[y] = make([]Point, ppm.Width+1)
+		for x := 0; x <= ppm.Width; x++ {
 			angle := 2.0 * math.Pi * rand.Float64()
 			grid[y][x] = Point{int(math.Cos(angle)), int(math.Sin(angle))}
 		}
 	}
 
+	// position relative de i dans [0, 1] ; une image d'un seul pixel de large ou de haut
+	// n'a pas de dernier indice non nul par lequel diviser
+	ratio := func(i, n int) float64 {
+		if n <= 1 {
+			return 0
+		}
+		return float64(i) / float64(n-1)
+	}
+
 	// Dessine le bruit de Perlin
 	for y := 0; y < ppm.Height; y++ {
 		for x := 0; x < ppm.Width; x++ {
 			// Interpolation bilinéaire pour obtenir la valeur de bruit
-			u := float64(x) / float64(ppm.Width-1)
-			v := float64(y) / float64(ppm.Height-1)
+			u := ratio(x, ppm.Width)
+			v := ratio(y, ppm.Height)
 			ix := u * float64(ppm.Width-1)
 			iy := v * float64(ppm.Height-1)
 			iu := int(ix)
@@ -626,8 +689,8 @@ func (ppm *PPM) DrawPerlinNoise(color1 Pixel, color2 Pixel) {
 
 // InterpolateColor réalise une interpolation linéaire entre deux couleurs.
 func InterpolateColor(color1 Pixel, color2 Pixel, t float64) Pixel {
-	r := uint8(float64(color1.R)*(1-t) + float64(color2.R)*t)
-	g := uint8(float64(color1.G)*(1-t) + float64(color2.G)*t)
-	b := uint8(float64(color1.B)*(1-t) + float64(color2.B)*t)
+	r := uint16(float64(color1.R)*(1-t) + float64(color2.R)*t)
+	g := uint16(float64(color1.G)*(1-t) + float64(color2.G)*t)
+	b := uint16(float64(color1.B)*(1-t) + float64(color2.B)*t)
 	return Pixel{r, g, b}
-}
\ No newline at end of file
+}
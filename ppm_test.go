@@ -0,0 +1,15 @@
+package netpbm
+
+import "testing"
+
+// TestPerlinNoiseThinImage vérifie que DrawPerlinNoise accepte une image d'un pixel de large ou de haut.
+func TestPerlinNoiseThinImage(t *testing.T) {
+	black, white := Pixel{0, 0, 0}, Pixel{255, 255, 255}
+	for _, size := range [][2]int{{1, 1}, {1, 5}, {5, 1}, {0, 0}} {
+		ppm := NewPPM(size[0], size[1], 255)
+		ppm.DrawPerlinNoise(black, white)
+		if err := ppm.validate(); err != nil {
+			t.Errorf("%dx%d: %v", size[0], size[1], err)
+		}
+	}
+}
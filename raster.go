@@ -0,0 +1,166 @@
+package netpbm
+
+import "image"
+
+// Raster est une grille de pixels de type quelconque stockée dans une seule tranche contiguë,
+// comme image.RGBA : chaque pixel occupe Step éléments consécutifs de Pix et la ligne y commence
+// à Pix[y*Stride]. Stride peut dépasser Width*Step lorsque la grille est une vue (voir View).
+// Les transformations géométriques sont écrites une seule fois ici et servent à toutes les
+// images du paquet, quel que soit le type de leurs échantillons.
+type Raster[T any] struct {
+	Pix           []T
+	Stride        int
+	Width, Height int
+	Step          int
+}
+
+// NewRaster crée une grille de width x height pixels de step éléments chacun, tous à zéro.
+func NewRaster[T any](width, height, step int) Raster[T] {
+	return Raster[T]{
+		Pix:    make([]T, width*height*step),
+		Stride: width * step,
+		Width:  width,
+		Height: height,
+		Step:   step,
+	}
+}
+
+// Contains indique si (x, y) désigne un pixel de la grille.
+func (r *Raster[T]) Contains(x, y int) bool {
+	return x >= 0 && x < r.Width && y >= 0 && y < r.Height
+}
+
+// Offset renvoie l'indice dans Pix du premier élément du pixel (x, y), ou -1 s'il est hors de la grille.
+func (r *Raster[T]) Offset(x, y int) int {
+	if !r.Contains(x, y) {
+		return -1
+	}
+	return y*r.Stride + x*r.Step
+}
+
+// Get renvoie le premier élément du pixel (x, y), ou la valeur nulle de T hors de la grille.
+func (r *Raster[T]) Get(x, y int) T {
+	var zero T
+	if i := r.Offset(x, y); i >= 0 {
+		return r.Pix[i]
+	}
+	return zero
+}
+
+// Set définit le premier élément du pixel (x, y) ; les coordonnées invalides sont ignorées.
+func (r *Raster[T]) Set(x, y int, value T) {
+	if i := r.Offset(x, y); i >= 0 {
+		r.Pix[i] = value
+	}
+}
+
+// Elems renvoie les Step éléments du pixel (x, y), qui partagent la mémoire de la grille,
+// ou nil si le pixel est hors de la grille.
+func (r *Raster[T]) Elems(x, y int) []T {
+	i := r.Offset(x, y)
+	if i < 0 {
+		return nil
+	}
+	return r.Pix[i : i+r.Step : i+r.Step]
+}
+
+// Row renvoie les Width*Step éléments de la ligne y, qui partagent la mémoire de la grille,
+// ou nil si la ligne est hors de la grille.
+func (r *Raster[T]) Row(y int) []T {
+	if y < 0 || y >= r.Height {
+		return nil
+	}
+	start := y * r.Stride
+	return r.Pix[start : start+r.Width*r.Step : start+r.Width*r.Step]
+}
+
+// Copy renvoie une copie indépendante et compacte de la grille.
+func (r *Raster[T]) Copy() Raster[T] {
+	c := NewRaster[T](r.Width, r.Height, r.Step)
+	for y := 0; y < r.Height; y++ {
+		copy(c.Row(y), r.Row(y))
+	}
+	return c
+}
+
+// View renvoie la partie de la grille comprise dans rect, sans copie : la vue partage Pix
+// avec la grille d'origine et ses coordonnées commencent à (0, 0). rect est d'abord restreint
+// à la grille. Les transformations qui changent les dimensions (Rotate90CW, Transpose, ...)
+// détachent la vue en lui allouant une nouvelle grille.
+func (r *Raster[T]) View(rect image.Rectangle) Raster[T] {
+	rect = rect.Intersect(image.Rect(0, 0, r.Width, r.Height))
+	if rect.Empty() {
+		return Raster[T]{Stride: r.Stride, Step: r.Step}
+	}
+	start := r.Offset(rect.Min.X, rect.Min.Y)
+	end := r.Offset(rect.Max.X-1, rect.Max.Y-1) + r.Step
+	return Raster[T]{
+		Pix:    r.Pix[start:end:end],
+		Stride: r.Stride,
+		Width:  rect.Dx(),
+		Height: rect.Dy(),
+		Step:   r.Step,
+	}
+}
+
+// swapPixels échange les pixels commençant aux indices i et j.
+func (r *Raster[T]) swapPixels(i, j int) {
+	for k := 0; k < r.Step; k++ {
+		r.Pix[i+k], r.Pix[j+k] = r.Pix[j+k], r.Pix[i+k]
+	}
+}
+
+// FlipH retourne la grille horizontalement (miroir gauche-droite).
+func (r *Raster[T]) FlipH() {
+	for y := 0; y < r.Height; y++ {
+		for x := 0; x < r.Width/2; x++ {
+			r.swapPixels(r.Offset(x, y), r.Offset(r.Width-1-x, y))
+		}
+	}
+}
+
+// FlipV retourne la grille verticalement (miroir haut-bas).
+func (r *Raster[T]) FlipV() {
+	for y := 0; y < r.Height/2; y++ {
+		top, bottom := r.Row(y), r.Row(r.Height-1-y)
+		for i := range top {
+			top[i], bottom[i] = bottom[i], top[i]
+		}
+	}
+}
+
+// Rotate180 fait pivoter la grille d'un demi-tour.
+func (r *Raster[T]) Rotate180() {
+	r.FlipH()
+	r.FlipV()
+}
+
+// remap remplace la grille par une grille de width x height pixels dont le pixel (x, y)
+// est le pixel src(x, y) de l'ancienne grille.
+func (r *Raster[T]) remap(width, height int, src func(x, y int) (int, int)) {
+	dst := NewRaster[T](width, height, r.Step)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			sx, sy := src(x, y)
+			copy(dst.Elems(x, y), r.Elems(sx, sy))
+		}
+	}
+	*r = dst
+}
+
+// Transpose échange les lignes et les colonnes de la grille.
+func (r *Raster[T]) Transpose() {
+	r.remap(r.Height, r.Width, func(x, y int) (int, int) { return y, x })
+}
+
+// Rotate90CW fait pivoter la grille de 90° dans le sens des aiguilles d'une montre.
+func (r *Raster[T]) Rotate90CW() {
+	h := r.Height
+	r.remap(r.Height, r.Width, func(x, y int) (int, int) { return y, h - 1 - x })
+}
+
+// Rotate90CCW fait pivoter la grille de 90° dans le sens inverse des aiguilles d'une montre.
+func (r *Raster[T]) Rotate90CCW() {
+	w := r.Width
+	r.remap(r.Height, r.Width, func(x, y int) (int, int) { return w - 1 - y, x })
+}
@@ -0,0 +1,201 @@
+package netpbm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// MaxValue16 est la plus grande valeur maximale autorisée par la norme netpbm.
+const MaxValue16 = 65535
+
+// newReader renvoie r s'il s'agit déjà d'un *bufio.Reader, afin de ne pas perdre de données déjà mises en tampon.
+func newReader(r io.Reader) *bufio.Reader {
+	if br, ok := r.(*bufio.Reader); ok {
+		return br
+	}
+	return bufio.NewReader(r)
+}
+
+// bytesPerSample renvoie le nombre d'octets d'un échantillon binaire : 1 jusqu'à 255, 2 au-delà.
+func bytesPerSample(max uint) int {
+	if max > 255 {
+		return 2
+	}
+	return 1
+}
+
+// readPlainSamples remplit samples d'échantillons ASCII de 0 à max séparés par des blancs.
+// En mode Lenient, les échantillons manquants ou invalides valent 0 et ceux qui dépassent
+// max sont ramenés à max.
+func readPlainSamples(hr *headerReader, samples []uint16, max uint) error {
+	if hr.truncated {
+		clear(samples)
+		return nil
+	}
+	expected := fmt.Sprintf("integer from 0 to %d", max)
+	for i := range samples {
+		token, err := hr.token()
+		if err == nil && token == "" {
+			err = io.EOF
+		}
+		if err != nil {
+			fe := hr.readError(err, "pixel data", fmt.Sprintf("%d samples", len(samples)), strconv.Itoa(i))
+			if fe.Err != ErrTruncated || hr.tolerate(fe) != nil {
+				return fe
+			}
+			hr.truncated = true
+			clear(samples[i:])
+			return nil
+		}
+		value, err := strconv.ParseUint(token, 10, 16)
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			value, err = MaxValue16, nil // trop grand : traité comme supérieur à max
+		}
+		if err != nil {
+			if err := hr.tolerate(hr.tokenError(ErrBadSample, "sample", expected, strconv.Quote(token))); err != nil {
+				return err
+			}
+			value = 0
+		}
+		if uint(value) > max {
+			if err := hr.tolerate(hr.tokenError(ErrBadSample, "sample", expected, strconv.Quote(token))); err != nil {
+				return err
+			}
+			value = uint64(max)
+		}
+		samples[i] = uint16(value)
+	}
+	return nil
+}
+
+// readRawSamples remplit samples d'échantillons binaires, sur 1 octet ou 2 octets big-endian selon max.
+// buf, de len(samples)*bytesPerSample(max) octets, sert de tampon de lecture : les lecteurs
+// le réutilisent d'une ligne à l'autre plutôt que de lire toute l'image d'un coup.
+// En mode Lenient, les échantillons manquants valent 0 et ceux qui dépassent max sont ramenés à max.
+func readRawSamples(hr *headerReader, samples []uint16, max uint, buf []byte) error {
+	if hr.truncated {
+		clear(samples)
+		return nil
+	}
+	size := bytesPerSample(max)
+	start := hr.offset
+	if err := hr.readFull(buf); err != nil {
+		fe := hr.readError(err, "pixel data", fmt.Sprintf("%d bytes", len(buf)), strconv.FormatInt(hr.offset-start, 10))
+		if fe.Err != ErrTruncated || hr.tolerate(fe) != nil {
+			return fe
+		}
+		hr.truncated = true
+		clear(buf[hr.offset-start:])
+	}
+	for i := range samples {
+		if size == 2 {
+			samples[i] = uint16(buf[2*i])<<8 | uint16(buf[2*i+1])
+		} else {
+			samples[i] = uint16(buf[i])
+		}
+		if uint(samples[i]) > max {
+			fe := &FormatError{Offset: start + int64(i*size), Line: int(hr.line), Field: "sample", Expected: fmt.Sprintf("integer from 0 to %d", max), Got: strconv.Itoa(int(samples[i])), Err: ErrBadSample}
+			if err := hr.tolerate(fe); err != nil {
+				return err
+			}
+			samples[i] = uint16(max)
+		}
+	}
+	return nil
+}
+
+// checkSamples vérifie qu'aucun échantillon de la ligne y ne dépasse max, avant toute écriture :
+// les écrivains binaires ne gardent que l'octet de poids faible d'un échantillon trop grand.
+func checkSamples(y int, samples []uint16, max uint) error {
+	for i, s := range samples {
+		if uint(s) > max {
+			return fmt.Errorf("sample %d of row %d is %d, above max value %d", i, y, s, max)
+		}
+	}
+	return nil
+}
+
+// maxPlainLineLength est la longueur maximale d'une ligne de données ASCII selon la norme netpbm.
+const maxPlainLineLength = 70
+
+// plainLine accumule une ligne de données ASCII et passe à la ligne avant qu'elle ne dépasse
+// maxPlainLineLength caractères.
+type plainLine struct {
+	w   io.Writer
+	buf []byte
+}
+
+// newPlainLine crée une ligne vide écrivant dans w.
+func newPlainLine(w io.Writer) *plainLine {
+	return &plainLine{w: w, buf: make([]byte, 0, maxPlainLineLength+1)}
+}
+
+// add ajoute token à la ligne, précédé d'une espace si spaced est vrai et que la ligne n'est pas vide.
+// Si token ne tient plus sur la ligne, celle-ci est d'abord écrite et token commence la suivante.
+func (l *plainLine) add(token []byte, spaced bool) error {
+	n := len(token)
+	if spaced && len(l.buf) > 0 {
+		n++
+	}
+	if len(l.buf) > 0 && len(l.buf)+n > maxPlainLineLength {
+		if err := l.end(); err != nil {
+			return err
+		}
+	}
+	if spaced && len(l.buf) > 0 {
+		l.buf = append(l.buf, ' ')
+	}
+	l.buf = append(l.buf, token...)
+	return nil
+}
+
+// end termine la ligne courante par un saut de ligne et l'écrit.
+func (l *plainLine) end() error {
+	l.buf = append(l.buf, '\n')
+	if _, err := l.w.Write(l.buf); err != nil {
+		return fmt.Errorf("failed to write pixel data: %v", err)
+	}
+	l.buf = l.buf[:0]
+	return nil
+}
+
+// writePlainSamples écrit une ligne de l'image en échantillons ASCII séparés par des espaces,
+// sur des lignes de texte d'au plus 70 caractères.
+func writePlainSamples(w io.Writer, samples []uint16) error {
+	line := newPlainLine(w)
+	var digits []byte
+	for _, s := range samples {
+		digits = strconv.AppendUint(digits[:0], uint64(s), 10)
+		if err := line.add(digits, true); err != nil {
+			return err
+		}
+	}
+	return line.end()
+}
+
+// writeRawSamples écrit des échantillons binaires, sur 1 octet ou 2 octets big-endian selon max.
+func writeRawSamples(w io.Writer, samples []uint16, max uint) error {
+	size := bytesPerSample(max)
+	buf := make([]byte, len(samples)*size)
+	for i, s := range samples {
+		if size == 2 {
+			buf[2*i], buf[2*i+1] = byte(s>>8), byte(s)
+		} else {
+			buf[i] = byte(s)
+		}
+	}
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("failed to write pixel data: %v", err)
+	}
+	return nil
+}
+
+// scaleSample convertit un échantillon de l'intervalle [0, from] vers [0, to], arrondi au plus proche.
+func scaleSample(value uint16, from, to uint) uint16 {
+	if from == to || from == 0 {
+		return value
+	}
+	return uint16((uint(value)*to + from/2) / from)
+}
@@ -0,0 +1,155 @@
+package netpbm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Naming choisit le nom du fichier réellement écrit par SaveWithOptions.
+type Naming int
+
+const (
+	// NamingExact écrit exactement le chemin demandé.
+	NamingExact Naming = iota
+	// NamingTimestamp ajoute la date et l'heure avant l'extension : "image-2006-01-02-15-04-05.pbm".
+	NamingTimestamp
+	// NamingVersioned écrit le premier nom libre parmi "image.pbm", "image-1.pbm", "image-2.pbm", ...
+	NamingVersioned
+)
+
+// SaveOptions règle l'enregistrement d'une image dans un fichier.
+type SaveOptions struct {
+	// Atomic écrit d'abord dans un fichier temporaire du même répertoire puis le renomme,
+	// de sorte qu'aucun lecteur ne voie jamais un fichier partiellement écrit.
+	Atomic bool
+	// NoClobber fait échouer l'enregistrement (erreur fs.ErrExist) si le fichier existe déjà.
+	NoClobber bool
+	// Naming choisit le nom du fichier écrit.
+	Naming Naming
+}
+
+// insertSuffix insère suffix juste avant l'extension de filename.
+func insertSuffix(filename, suffix string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + suffix + ext
+}
+
+// candidateName renvoie le nom à essayer pour la tentative n (n = 0 pour la première).
+func candidateName(filename string, naming Naming, n int) string {
+	if naming != NamingVersioned || n == 0 {
+		return filename
+	}
+	return insertSuffix(filename, fmt.Sprintf("-%d", n))
+}
+
+// saveFile écrit une image avec encode selon opts et renvoie le chemin réellement écrit.
+// validate est appelée avant de toucher au moindre fichier. Un fichier existant n'est jamais
+// tronqué ni supprimé en cas d'échec : lorsqu'il doit être écrasé, il est remplacé d'un coup
+// par un fichier temporaire, comme avec Atomic.
+func saveFile(filename string, opts SaveOptions, validate func() error, encode func(io.Writer) error) (string, error) {
+	if err := validate(); err != nil {
+		return "", err
+	}
+	if opts.Naming == NamingTimestamp {
+		filename = insertSuffix(filename, time.Now().Format("-2006-01-02-15-04-05"))
+	}
+	exclusive := opts.NoClobber || opts.Naming == NamingVersioned
+	if opts.Atomic {
+		return saveAtomic(filename, opts.Naming, exclusive, encode)
+	}
+
+	for n := 0; ; n++ {
+		name := candidateName(filename, opts.Naming, n)
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+		if errors.Is(err, fs.ErrExist) && opts.Naming == NamingVersioned {
+			continue
+		}
+		if errors.Is(err, fs.ErrExist) && !exclusive {
+			return saveAtomic(name, opts.Naming, false, encode)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create file: %w", err)
+		}
+		// le fichier vient d'être créé par cet appel : il peut être supprimé en cas d'échec
+		if err := encode(file); err != nil {
+			file.Close()
+			os.Remove(name)
+			return "", err
+		}
+		if err := file.Close(); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+}
+
+// createTemp crée un fichier temporaire de nom prefix suivi d'un suffixe aléatoire dans dir.
+// Contrairement à os.CreateTemp, qui impose les permissions 0o600, il crée le fichier avec
+// 0o666 diminué de l'umask, comme le fait l'enregistrement non atomique.
+func createTemp(dir, prefix string) (*os.File, error) {
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 36))
+		file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return file, err
+	}
+	return nil, &fs.PathError{Op: "createtemp", Path: filepath.Join(dir, prefix+"*"), Err: fs.ErrExist}
+}
+
+// saveAtomic écrit l'image dans un fichier temporaire puis le met en place par renommage,
+// ou par lien physique lorsque le fichier de destination ne doit pas être écrasé.
+func saveAtomic(filename string, naming Naming, exclusive bool, encode func(io.Writer) error) (string, error) {
+	tmp, err := createTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := encode(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	// comme os.OpenFile avec O_TRUNC, le remplacement conserve les permissions du fichier existant
+	if info, err := os.Stat(filename); err == nil && !exclusive {
+		if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+			tmp.Close()
+			return "", err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	if !exclusive {
+		if err := os.Rename(tmp.Name(), filename); err != nil {
+			return "", fmt.Errorf("failed to rename temporary file: %w", err)
+		}
+		return filename, nil
+	}
+	// os.Link échoue si la destination existe : c'est le « no clobber » atomique
+	for n := 0; ; n++ {
+		name := candidateName(filename, naming, n)
+		err := os.Link(tmp.Name(), name)
+		if errors.Is(err, fs.ErrExist) && naming == NamingVersioned {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create file: %w", err)
+		}
+		return name, nil
+	}
+}
@@ -0,0 +1,280 @@
+package netpbm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// saveModes énumère les combinaisons d'Atomic et de NoClobber.
+var saveModes = []struct {
+	name string
+	opts SaveOptions
+}{
+	{"plain", SaveOptions{}},
+	{"atomic", SaveOptions{Atomic: true}},
+	{"noclobber", SaveOptions{NoClobber: true}},
+	{"atomic+noclobber", SaveOptions{Atomic: true, NoClobber: true}},
+}
+
+// dirEntries renvoie les noms triés des fichiers de dir.
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeExisting crée dir/name avec le contenu content et les permissions perm.
+func writeExisting(t *testing.T, dir, name string, content []byte, perm fs.FileMode) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// encoded renvoie l'image encodée par Encode.
+func encoded(t *testing.T, pgm *PGM) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := pgm.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestSaveNew vérifie que chaque mode crée le fichier demandé, sans fichier temporaire résiduel.
+func TestSaveNew(t *testing.T) {
+	pgm := NewPGM(3, 2, 255)
+	pgm.Set(1, 1, 200)
+	for _, mode := range saveModes {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.pgm")
+		name, err := pgm.SaveWithOptions(path, mode.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", mode.name, err)
+		}
+		if name != path {
+			t.Errorf("%s: wrote %s, want %s", mode.name, name, path)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, encoded(t, pgm)) {
+			t.Errorf("%s: file content differs from Encode", mode.name)
+		}
+		if names := dirEntries(t, dir); len(names) != 1 {
+			t.Errorf("%s: directory holds %v", mode.name, names)
+		}
+	}
+}
+
+// TestSaveExisting vérifie qu'un fichier existant est remplacé, ou laissé intact avec
+// fs.ErrExist sous NoClobber.
+func TestSaveExisting(t *testing.T) {
+	pgm := NewPGM(2, 2, 255)
+	old := []byte("old content")
+	for _, mode := range saveModes {
+		dir := t.TempDir()
+		path := writeExisting(t, dir, "a.pgm", old, 0o644)
+		_, err := pgm.SaveWithOptions(path, mode.opts)
+		want := encoded(t, pgm)
+		if mode.opts.NoClobber {
+			if !errors.Is(err, fs.ErrExist) {
+				t.Errorf("%s: got error %v, want fs.ErrExist", mode.name, err)
+			}
+			want = old
+		} else if err != nil {
+			t.Fatalf("%s: %v", mode.name, err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: file holds %q", mode.name, got)
+		}
+		if names := dirEntries(t, dir); len(names) != 1 {
+			t.Errorf("%s: directory holds %v", mode.name, names)
+		}
+	}
+}
+
+// TestSaveVersioned vérifie que NamingVersioned choisit le premier nom libre sans écraser
+// les fichiers existants.
+func TestSaveVersioned(t *testing.T) {
+	pgm := NewPGM(2, 2, 255)
+	for _, mode := range saveModes {
+		dir := t.TempDir()
+		writeExisting(t, dir, "a.pgm", []byte("old"), 0o644)
+		writeExisting(t, dir, "a-2.pgm", []byte("old"), 0o644)
+		opts := mode.opts
+		opts.Naming = NamingVersioned
+		var written []string
+		for i := 0; i < 2; i++ {
+			name, err := pgm.SaveWithOptions(filepath.Join(dir, "a.pgm"), opts)
+			if err != nil {
+				t.Fatalf("%s: %v", mode.name, err)
+			}
+			written = append(written, filepath.Base(name))
+		}
+		if written[0] != "a-1.pgm" || written[1] != "a-3.pgm" {
+			t.Errorf("%s: wrote %v, want [a-1.pgm a-3.pgm]", mode.name, written)
+		}
+		for _, name := range []string{"a.pgm", "a-2.pgm"} {
+			if got, _ := os.ReadFile(filepath.Join(dir, name)); string(got) != "old" {
+				t.Errorf("%s: %s was overwritten", mode.name, name)
+			}
+		}
+		if names := dirEntries(t, dir); len(names) != 4 {
+			t.Errorf("%s: directory holds %v", mode.name, names)
+		}
+	}
+}
+
+// TestSaveTimestamp vérifie que NamingTimestamp insère la date avant l'extension.
+func TestSaveTimestamp(t *testing.T) {
+	pgm := NewPGM(2, 2, 255)
+	pattern := regexp.MustCompile(`^a-\d{4}-\d{2}-\d{2}-\d{2}-\d{2}-\d{2}\.pgm$`)
+	for _, mode := range saveModes {
+		dir := t.TempDir()
+		opts := mode.opts
+		opts.Naming = NamingTimestamp
+		name, err := pgm.SaveWithOptions(filepath.Join(dir, "a.pgm"), opts)
+		if err != nil {
+			t.Fatalf("%s: %v", mode.name, err)
+		}
+		if filepath.Dir(name) != dir || !pattern.MatchString(filepath.Base(name)) {
+			t.Errorf("%s: wrote %s", mode.name, name)
+		}
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s: %v", mode.name, err)
+		}
+	}
+}
+
+// TestSavePermissions vérifie qu'un nouveau fichier reçoit 0o666 diminué de l'umask dans tous
+// les modes et qu'un fichier remplacé garde ses permissions.
+func TestSavePermissions(t *testing.T) {
+	pgm := NewPGM(2, 2, 255)
+	for _, mode := range saveModes {
+		dir := t.TempDir()
+
+		// fichier de référence créé comme le ferait os.Create
+		ref, err := os.OpenFile(filepath.Join(dir, "ref"), os.O_WRONLY|os.O_CREATE, 0o666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref.Close()
+		refInfo, err := os.Stat(ref.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, err := pgm.SaveWithOptions(filepath.Join(dir, "new.pgm"), mode.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", mode.name, err)
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != refInfo.Mode().Perm() {
+			t.Errorf("%s: new file has mode %v, want %v", mode.name, info.Mode().Perm(), refInfo.Mode().Perm())
+		}
+
+		if mode.opts.NoClobber {
+			continue
+		}
+		path := writeExisting(t, dir, "old.pgm", []byte("old"), 0o600)
+		if _, err := pgm.SaveWithOptions(path, mode.opts); err != nil {
+			t.Fatalf("%s: %v", mode.name, err)
+		}
+		info, err = os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("%s: replaced file has mode %v, want 0600", mode.name, info.Mode().Perm())
+		}
+	}
+}
+
+// TestSaveFailureKeepsFile vérifie qu'un enregistrement qui échoue ne tronque ni ne supprime
+// le fichier existant et ne laisse aucun fichier derrière lui.
+func TestSaveFailureKeepsFile(t *testing.T) {
+	old := []byte("P5\n1 1\n255\n\x80")
+	invalid := map[string]func() *PGM{
+		"magic number": func() *PGM {
+			pgm := NewPGM(2, 2, 255)
+			pgm.MagicNumber = "P6"
+			return pgm
+		},
+		"max value": func() *PGM { return NewPGM(2, 2, 0) },
+		"sample": func() *PGM {
+			pgm := NewPGM(2, 2, 15)
+			pgm.Set(1, 0, 16)
+			return pgm
+		},
+	}
+	for what, image := range invalid {
+		for _, mode := range saveModes {
+			for _, naming := range []Naming{NamingExact, NamingVersioned} {
+				dir := t.TempDir()
+				path := writeExisting(t, dir, "a.pgm", old, 0o644)
+				opts := mode.opts
+				opts.Naming = naming
+				if _, err := image().SaveWithOptions(path, opts); err == nil {
+					t.Fatalf("%s, %s, naming %d: invalid %s saved", mode.name, what, naming, what)
+				}
+				if got, _ := os.ReadFile(path); !bytes.Equal(got, old) {
+					t.Errorf("%s, %s, naming %d: existing file holds %q", mode.name, what, naming, got)
+				}
+				if names := dirEntries(t, dir); len(names) != 1 {
+					t.Errorf("%s, %s, naming %d: directory holds %v", mode.name, what, naming, names)
+				}
+			}
+		}
+	}
+
+	// une erreur d'écriture en cours d'encodage ne touche pas non plus au fichier existant
+	failing := func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return errors.New("write failed")
+	}
+	valid := func() error { return nil }
+	for _, mode := range saveModes {
+		dir := t.TempDir()
+		path := writeExisting(t, dir, "a.pgm", old, 0o644)
+		if _, err := saveFile(path, mode.opts, valid, failing); err == nil {
+			t.Fatalf("%s: failing encode succeeded", mode.name)
+		}
+		if got, _ := os.ReadFile(path); !bytes.Equal(got, old) {
+			t.Errorf("%s: existing file holds %q", mode.name, got)
+		}
+		if _, err := saveFile(filepath.Join(dir, "b.pgm"), mode.opts, valid, failing); err == nil {
+			t.Fatalf("%s: failing encode succeeded", mode.name)
+		}
+		if names := dirEntries(t, dir); len(names) != 1 {
+			t.Errorf("%s: directory holds %v", mode.name, names)
+		}
+	}
+}
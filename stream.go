@@ -0,0 +1,192 @@
+package netpbm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// RowReader lit une image netpbm (P1 à P7) ligne par ligne, sans jamais la charger entièrement
+// en mémoire : seule la ligne courante est lue, ce qui permet de filtrer des images plus grandes
+// que la mémoire disponible.
+//
+// Les échantillons suivent la convention de Image.Sample : Depth échantillons entrelacés par pixel,
+// de 0 (noir) à Max ; pour une image PBM, 0 désigne un pixel noir et 1 un pixel blanc.
+type RowReader struct {
+	hr     *headerReader
+	header Header
+	y      int
+	buf    []byte // tampon de lecture des lignes binaires, réutilisé d'une ligne à l'autre
+}
+
+// NewRowReader lit l'en-tête de l'image depuis r et renvoie un lecteur positionné sur sa première ligne.
+func NewRowReader(r io.Reader) (*RowReader, error) {
+	hr := newHeaderReader(r)
+	header, err := probe(hr, "P1 to P7", func(m string) bool { return FormatOf(m) != 0 && FormatOf(m) != FormatPFM })
+	if err != nil {
+		return nil, err
+	}
+	return &RowReader{hr: hr, header: header}, nil
+}
+
+// Header renvoie l'en-tête de l'image.
+func (rr *RowReader) Header() Header {
+	return rr.header
+}
+
+// ReadRow lit la ligne suivante dans row, qui doit contenir Width*Depth échantillons.
+// Il renvoie io.EOF une fois toutes les lignes lues.
+func (rr *RowReader) ReadRow(row []uint16) error {
+	h := rr.header
+	if rr.y >= h.Height {
+		return io.EOF
+	}
+	if len(row) != h.Width*h.Depth {
+		return fmt.Errorf("row has %d samples, want %d", len(row), h.Width*h.Depth)
+	}
+
+	var err error
+	switch h.MagicNumber {
+	case "P1":
+		for x := range row {
+			row[x] = 1
+		}
+		err = readPBMPlain(rr.hr, h.Width, 1, func(x, _ int) { row[x] = 0 })
+	case "P4":
+		err = readPBMRaw(rr.hr, h.Width, 1, func(_ int, packed []byte) {
+			for x := range row {
+				row[x] = 1
+				if packed[x/8]&(0x80>>uint(x%8)) != 0 {
+					row[x] = 0
+				}
+			}
+		})
+	case "P2", "P3":
+		err = readPlainSamples(rr.hr, row, h.Max)
+	default:
+		if rr.buf == nil {
+			rr.buf = make([]byte, len(row)*bytesPerSample(h.Max))
+		}
+		err = readRawSamples(rr.hr, row, h.Max, rr.buf)
+	}
+	if err != nil {
+		return err
+	}
+	rr.y++
+	return nil
+}
+
+// RowWriter écrit une image netpbm (P1 à P7) ligne par ligne, avec les mêmes conventions que RowReader.
+type RowWriter struct {
+	w      *bufio.Writer
+	header Header
+	y      int
+}
+
+// NewRowWriter écrit l'en-tête décrit par header dans w et renvoie un écrivain attendant la première ligne.
+// Les commentaires de header suivent le numéro magique. Format est déduit de MagicNumber ; Depth est
+// toujours imposé pour PBM, PGM et PPM (1, 1 et 3), de même que Max pour PBM (1). Pour les autres
+// formats, Max doit être compris entre 1 et 65535.
+func NewRowWriter(w io.Writer, header Header) (*RowWriter, error) {
+	header.Format = FormatOf(header.MagicNumber)
+	switch header.Format {
+	case FormatPBM:
+		header.Depth, header.Max = 1, 1
+	case FormatPGM:
+		header.Depth = 1
+	case FormatPPM:
+		header.Depth = 3
+	case FormatPAM:
+		if header.Depth < 1 {
+			return nil, fmt.Errorf("invalid depth: %d", header.Depth)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported magic number: %q", header.MagicNumber)
+	}
+	if header.Max < 1 || header.Max > MaxValue16 {
+		return nil, fmt.Errorf("unsupported max value: %d", header.Max)
+	}
+	if err := checkTupleType(header.TupleType); err != nil {
+		return nil, err
+	}
+
+	rw := &RowWriter{w: bufio.NewWriter(w), header: header}
+	if _, err := fmt.Fprintf(rw.w, "%s\n", header.MagicNumber); err != nil {
+		return nil, fmt.Errorf("failed to write header: %v", err)
+	}
+	if err := writeComments(rw.w, header.Comments); err != nil {
+		return nil, err
+	}
+	var err error
+	switch header.Format {
+	case FormatPBM:
+		_, err = fmt.Fprintf(rw.w, "%d %d\n", header.Width, header.Height)
+	case FormatPAM:
+		_, err = fmt.Fprintf(rw.w, "WIDTH %d\nHEIGHT %d\nDEPTH %d\nMAXVAL %d\n", header.Width, header.Height, header.Depth, header.Max)
+		if err == nil && header.TupleType != "" {
+			_, err = fmt.Fprintf(rw.w, "TUPLTYPE %s\n", header.TupleType)
+		}
+		if err == nil {
+			_, err = fmt.Fprint(rw.w, "ENDHDR\n")
+		}
+	default:
+		_, err = fmt.Fprintf(rw.w, "%d %d\n%d\n", header.Width, header.Height, header.Max)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write header: %v", err)
+	}
+	return rw, nil
+}
+
+// Header renvoie l'en-tête de l'image, complété par NewRowWriter.
+func (rw *RowWriter) Header() Header {
+	return rw.header
+}
+
+// WriteRow écrit la ligne suivante ; row doit contenir Width*Depth échantillons compris entre 0 et Max.
+func (rw *RowWriter) WriteRow(row []uint16) error {
+	h := rw.header
+	if rw.y >= h.Height {
+		return fmt.Errorf("too many rows: image has %d", h.Height)
+	}
+	if len(row) != h.Width*h.Depth {
+		return fmt.Errorf("row has %d samples, want %d", len(row), h.Width*h.Depth)
+	}
+	if err := checkSamples(rw.y, row, h.Max); err != nil {
+		return err
+	}
+
+	var err error
+	switch h.MagicNumber {
+	case "P1":
+		err = writePBMPlain(rw.w, h.Width, 1, false, func(x, _ int) bool { return row[x] == 0 })
+	case "P4":
+		err = writePBMRaw(rw.w, h.Width, 1, func(_ int, packed []byte) {
+			for x, v := range row {
+				if v == 0 {
+					packed[x/8] |= 0x80 >> uint(x%8)
+				}
+			}
+		})
+	case "P2", "P3":
+		err = writePlainSamples(rw.w, row)
+	default:
+		err = writeRawSamples(rw.w, row, h.Max)
+	}
+	if err != nil {
+		return err
+	}
+	rw.y++
+	return nil
+}
+
+// Close vide le tampon et vérifie que toutes les lignes ont été écrites ; il ne ferme pas le io.Writer sous-jacent.
+func (rw *RowWriter) Close() error {
+	if err := rw.w.Flush(); err != nil {
+		return err
+	}
+	if rw.y != rw.header.Height {
+		return fmt.Errorf("image incomplete: wrote %d rows, want %d", rw.y, rw.header.Height)
+	}
+	return nil
+}
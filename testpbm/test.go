@@ -18,8 +18,8 @@ func testpbm() {
 	fmt.Printf("Width: %d\n", pbm.Width)
 	fmt.Printf("Height: %d\n", pbm.Height)
 	fmt.Println("Data:")
-	for _, row := range pbm.Data {
-		fmt.Println(row)
+	for y := 0; y < pbm.Height; y++ {
+		fmt.Println(pbm.Row(y))
 	}
 
 }
@@ -18,9 +18,9 @@ func testpgm() {
 	fmt.Printf("Width: %d\n", pgm.Width)
 	fmt.Printf("Height: %d\n", pgm.Height)
 	fmt.Println("Data:")
-	fmt.Println("Max: %s\n", pgm.Max)
-	for _, row := range pgm.Data {
-		fmt.Println(row)
+	fmt.Printf("Max: %d\n", pgm.Max)
+	for y := 0; y < pgm.Height; y++ {
+		fmt.Println(pgm.Row(y))
 	}
 
 }
@@ -8,7 +8,7 @@ import (
 
 func testppm() {
 	filename := "../duckP3.ppm"
-	ppm, err := netppm.ReadPBM(filename)
+	ppm, err := netppm.ReadPPM(filename)
 	if err != nil {
 		fmt.Println("error", err)
 		return
@@ -18,8 +18,8 @@ func testppm() {
 	fmt.Printf("Width: %d\n", ppm.Width)
 	fmt.Printf("Height: %d\n", ppm.Height)
 	fmt.Println("Data:")
-	for _, row := range ppm.Data {
-		fmt.Println(row)
+	for y := 0; y < ppm.Height; y++ {
+		fmt.Println(ppm.Row(y))
 	}
 
 }
\ No newline at end of file